@@ -8,23 +8,44 @@ import (
 // AI interface defines the behaviour of a blackjack AI
 type AI interface {
 	Play(hand []deck.Card, dealer deck.Card) Move
-	// Bet an amount of money, knowing if the deck has recently been suffled
-	Bet(shuffled bool) int
+	// Bet an amount of money, knowing if the deck has recently been suffled,
+	// how much money is left and the limits of the table
+	Bet(shuffled bool, table Table) int
+	// Results shows a final hand of the player. When the player has several
+	// hands, the dealer cards are only given with the last one.
 	Results(hand []deck.Card, dealer []deck.Card)
 }
 
-type dealerAI struct{}
+// ExposedAI is implemented by the AIs that want to see both cards of the
+// dealer when they are face up (in Double Exposure)
+type ExposedAI interface {
+	PlayExposed(hand []deck.Card, dealer []deck.Card) Move
+}
+
+// SwitchAI is implemented by the AIs that want to decide whether to swap the
+// second cards of their two hands in Blackjack Switch
+type SwitchAI interface {
+	Switch(first, second []deck.Card, dealer deck.Card) bool
+}
+
+type dealerAI struct {
+	standSoft17 bool
+}
 
 // Play returns a Move for the dealer
 func (ai dealerAI) Play(hand []deck.Card, dealer deck.Card) Move {
-	dScore := Score(hand...)
-	if dScore <= 16 || dScore == 17 && Soft(hand...) {
+	if dealerHits(Score(hand...), Soft(hand...), ai.standSoft17) {
 		return MoveHit
 	}
 	return MoveStand
 }
 
-func (ai dealerAI) Bet(shuffled bool) int {
+// dealerHits returns true if the dealer has to draw another card
+func dealerHits(score int, soft bool, standSoft17 bool) bool {
+	return score <= 16 || score == 17 && soft && !standSoft17
+}
+
+func (ai dealerAI) Bet(shuffled bool, table Table) int {
 	// Do nothing
 	return 1
 }
@@ -65,12 +86,21 @@ func (ai humanAI) Play(hand []deck.Card, dealer deck.Card) Move {
 	}
 }
 
-func (ai humanAI) Bet(shuffled bool) int {
+func (ai humanAI) Bet(shuffled bool, table Table) int {
 	fmt.Println("----------------------------------------")
 	if shuffled {
 		fmt.Println("The deck was just shuffled")
 	}
-	fmt.Println("What whould you like to bet?")
+	if table.Bankroll > 0 {
+		fmt.Println("Bankroll:", table.Bankroll)
+	} else {
+		fmt.Println("Balance:", table.Balance)
+	}
+	if table.MaxBet > 0 {
+		fmt.Printf("What whould you like to bet? (%d to %d)\n", table.MinBet, table.MaxBet)
+	} else {
+		fmt.Printf("What whould you like to bet? (at least %d)\n", table.MinBet)
+	}
 	var bet int
 	fmt.Scanf("%d\n", &bet)
 	return bet
@@ -80,7 +110,9 @@ func (ai humanAI) Bet(shuffled bool) int {
 func (ai humanAI) Results(hand []deck.Card, dealer []deck.Card) {
 	fmt.Println("=== FINAL HANDS ===")
 	fmt.Printf("Player: %s.\n", hand)
-	fmt.Printf("Dealer: %s.\n", dealer)
+	if len(dealer) > 0 {
+		fmt.Printf("Dealer: %s.\n", dealer)
+	}
 }
 
 type basicAI struct{}
@@ -105,11 +137,8 @@ func (ai basicAI) Play(hand []deck.Card, dealer deck.Card) Move {
 	}
 }
 
-func (ai basicAI) Bet(shuffled bool) int {
-	//rand.Seed(time.Now().Unix())
-	//minBet := 100
-	//return rand.Intn(1+minBet*10) + 100
-	return 100
+func (ai basicAI) Bet(shuffled bool, table Table) int {
+	return table.MinBet
 }
 
 func (ai basicAI) Results(hand []deck.Card, dealer []deck.Card) {
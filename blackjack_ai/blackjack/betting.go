@@ -0,0 +1,275 @@
+package blackjack
+
+import (
+	"gophercises/deck"
+	"math"
+)
+
+// A BettingSystem decides how much to bet on each hand. It sees the results
+// of every hand so that it can follow a progression or count cards.
+type BettingSystem interface {
+	Bet(shuffled bool, table Table) int
+	Results(hand []deck.Card, dealer []deck.Card)
+}
+
+type bettor struct {
+	AI
+	system BettingSystem
+}
+
+// Bettor returns an AI that plays like ai but sizes its bets with system
+func Bettor(ai AI, system BettingSystem) AI {
+	return bettor{AI: ai, system: system}
+}
+
+func (b bettor) Bet(shuffled bool, table Table) int {
+	return b.system.Bet(shuffled, table)
+}
+
+func (b bettor) Results(hand []deck.Card, dealer []deck.Card) {
+	b.system.Results(hand, dealer)
+	b.AI.Results(hand, dealer)
+}
+
+// The optional methods of the AI are forwarded, doing what the game does
+// for the AIs without them
+
+func (b bettor) SideBet(name string, table Table) int {
+	if s, ok := b.AI.(SideBettor); ok {
+		return s.SideBet(name, table)
+	}
+	return 0
+}
+
+func (b bettor) PlayExposed(hand []deck.Card, dealer []deck.Card) Move {
+	if exposed, ok := b.AI.(ExposedAI); ok {
+		return exposed.PlayExposed(hand, dealer)
+	}
+	return b.AI.Play(hand, dealer[0])
+}
+
+func (b bettor) Switch(first, second []deck.Card, dealer deck.Card) bool {
+	if s, ok := b.AI.(SwitchAI); ok {
+		return s.Switch(first, second, dealer)
+	}
+	return improves(first, second)
+}
+
+// limit keeps a bet within the table limits and what the player can
+// afford, when the table has a bankroll
+func limit(bet int, table Table) int {
+	if table.MaxBet > 0 && bet > table.MaxBet {
+		bet = table.MaxBet
+	}
+	if table.Bankroll > 0 && bet > table.Bankroll {
+		bet = table.Bankroll
+	}
+	if bet < table.MinBet {
+		bet = table.MinBet
+	}
+	return bet
+}
+
+// outcome returns the result of the main bets of the previous hand: 1 for
+// a win, -1 for a loss and 0 for a push (or no hand). The side bets don't
+// count.
+func outcome(table Table) int {
+	switch {
+	case table.LastHand > 0:
+		return 1
+	case table.LastHand < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+type flat struct {
+	amount int
+}
+
+// Flat always bets the same amount (the table minimum if amount is 0)
+func Flat(amount int) BettingSystem {
+	return &flat{amount: amount}
+}
+
+func (f *flat) Bet(shuffled bool, table Table) int {
+	return limit(f.amount, table)
+}
+
+func (f *flat) Results(hand []deck.Card, dealer []deck.Card) {}
+
+type martingale struct {
+	base int
+	bet  int
+}
+
+// Martingale doubles the bet after each loss and goes back to base after a
+// win
+func Martingale(base int) BettingSystem {
+	return &martingale{base: base, bet: base}
+}
+
+func (m *martingale) Bet(shuffled bool, table Table) int {
+	switch outcome(table) {
+	case 1:
+		m.bet = m.base
+	case -1:
+		m.bet *= 2
+	}
+	return limit(m.bet, table)
+}
+
+func (m *martingale) Results(hand []deck.Card, dealer []deck.Card) {}
+
+type progression struct {
+	base  int
+	steps []int
+	step  int
+}
+
+// Paroli doubles the bet after each win, and goes back to base after a loss
+// or three wins in a row
+func Paroli(base int) BettingSystem {
+	return &progression{base: base, steps: []int{1, 2, 4}}
+}
+
+// OneThreeTwoSix bets 1, 3, 2 then 6 times base on consecutive wins, and
+// goes back to base after a loss or a completed sequence
+func OneThreeTwoSix(base int) BettingSystem {
+	return &progression{base: base, steps: []int{1, 3, 2, 6}}
+}
+
+func (p *progression) Bet(shuffled bool, table Table) int {
+	switch outcome(table) {
+	case 1:
+		p.step = (p.step + 1) % len(p.steps)
+	case -1:
+		p.step = 0
+	}
+	return limit(p.base*p.steps[p.step], table)
+}
+
+func (p *progression) Results(hand []deck.Card, dealer []deck.Card) {}
+
+type kelly struct {
+	fraction float64
+	decks    int
+	deckSize int
+	count    int
+	seen     int
+}
+
+// Kelly bets a fraction of the Kelly criterion, estimating the player's
+// edge from the Hi-Lo true count of a shoe of the given number of decks.
+// It bets the table minimum when the count gives no advantage, or when
+// the table has no bankroll to size the bets from.
+func Kelly(fraction float64, decks int) BettingSystem {
+	return &kelly{fraction: fraction, decks: decks}
+}
+
+// Each point of true count is worth about half a percent of edge, starting
+// from a house edge of half a percent. A hand of blackjack has a variance of
+// about 1.3 squared units.
+const (
+	baseEdge     = -0.005
+	edgePerCount = 0.005
+	handVariance = 1.3
+)
+
+func (k *kelly) Bet(shuffled bool, table Table) int {
+	if shuffled {
+		k.count, k.seen = 0, 0
+	}
+	k.deckSize = table.DeckSize
+	edge := baseEdge + edgePerCount*k.trueCount()
+	if edge <= 0 || table.Bankroll <= 0 {
+		return limit(table.MinBet, table)
+	}
+	bet := k.fraction * edge / handVariance * float64(table.Bankroll)
+	return limit(int(math.Floor(bet)), table)
+}
+
+func (k *kelly) trueCount() float64 {
+	size := k.deckSize
+	if size == 0 {
+		size = 52
+	}
+	decksLeft := float64(size*k.decks-k.seen) / float64(size)
+	if decksLeft < 0.5 {
+		decksLeft = 0.5
+	}
+	return float64(k.count) / decksLeft
+}
+
+func (k *kelly) Results(hand []deck.Card, dealer []deck.Card) {
+	for _, card := range hand {
+		k.hiLo(card)
+	}
+	for _, card := range dealer {
+		k.hiLo(card)
+	}
+}
+
+func (k *kelly) hiLo(c deck.Card) {
+	score := Score(c)
+	switch {
+	case score >= 10:
+		k.count--
+	case score <= 6:
+		k.count++
+	}
+	k.seen++
+}
+
+// RuinPoint is the result of many sessions started with the same bankroll
+type RuinPoint struct {
+	Bankroll int
+	// RiskOfRuin is the proportion of sessions where the player lost so much
+	// that they couldn't afford the minimum bet anymore
+	RiskOfRuin float64
+	// WinGoal and StopLoss are the proportions of sessions ended by the win
+	// goal and the stop-loss
+	WinGoal  float64
+	StopLoss float64
+	// EV is the average winnings of a session
+	EV float64
+}
+
+// RuinCurve plays the given number of sessions for each starting bankroll
+// and returns the risk of ruin for each of them. newAI is called for every
+// session. The Bankroll of opts is ignored.
+func RuinCurve(opts Options, newAI func() AI, sessions int, bankrolls []int) []RuinPoint {
+	curve := make([]RuinPoint, 0, len(bankrolls))
+	for _, bankroll := range bankrolls {
+		point := RuinPoint{Bankroll: bankroll}
+		for i := 0; i < sessions; i++ {
+			sessionOpts := opts
+			sessionOpts.Bankroll = bankroll
+			if opts.Seed != 0 {
+				sessionOpts.Seed = roundSeed(opts.Seed, i)
+			}
+			g := New(sessionOpts)
+			res := g.Session(newAI())
+			if res.Ruined {
+				point.RiskOfRuin++
+			}
+			if res.WinGoal {
+				point.WinGoal++
+			}
+			if res.StopLoss {
+				point.StopLoss++
+			}
+			point.EV += float64(res.Balance)
+		}
+		if sessions > 0 {
+			n := float64(sessions)
+			point.RiskOfRuin /= n
+			point.WinGoal /= n
+			point.StopLoss /= n
+			point.EV /= n
+		}
+		curve = append(curve, point)
+	}
+	return curve
+}
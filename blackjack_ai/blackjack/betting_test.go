@@ -0,0 +1,122 @@
+package blackjack
+
+import (
+	"gophercises/deck"
+	"testing"
+)
+
+func TestProgressions(t *testing.T) {
+	tests := []struct {
+		name      string
+		system    BettingSystem
+		lastHands []int
+		want      []int
+	}{
+		{
+			name:      "martingale",
+			system:    Martingale(100),
+			lastHands: []int{0, -100, -200, 0, 400},
+			want:      []int{100, 200, 400, 400, 100},
+		},
+		{
+			name:      "paroli",
+			system:    Paroli(100),
+			lastHands: []int{0, 100, 200, 400, -100},
+			want:      []int{100, 200, 400, 100, 100},
+		},
+		{
+			name:      "1-3-2-6",
+			system:    OneThreeTwoSix(100),
+			lastHands: []int{0, 100, 300, 200, 600},
+			want:      []int{100, 300, 200, 600, 100},
+		},
+	}
+	for _, test := range tests {
+		for i, lastHand := range test.lastHands {
+			got := test.system.Bet(false, Table{LastHand: lastHand, MinBet: 100})
+			if got != test.want[i] {
+				t.Errorf("%s: expected a bet of %d at step %d. Got %d", test.name, test.want[i], i, got)
+			}
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	table := Table{Bankroll: 250, MinBet: 100, MaxBet: 1000}
+	if got := Martingale(800).Bet(false, table); got != 250 {
+		t.Errorf("Expected the bet to be limited by the balance. Got %d", got)
+	}
+	table.Bankroll = 5000
+	if got := Flat(2000).Bet(false, table); got != 1000 {
+		t.Errorf("Expected the bet to be limited by the table maximum. Got %d", got)
+	}
+	// Without a bankroll, the winnings don't limit the bets
+	if got := Flat(500).Bet(false, Table{Balance: 200, MinBet: 100}); got != 500 {
+		t.Errorf("Expected the bet not to be limited by the winnings. Got %d", got)
+	}
+	if got := Kelly(1, 3).Bet(false, Table{Balance: 1000000, MinBet: 100}); got != 100 {
+		t.Errorf("Expected Kelly to bet the minimum without a bankroll. Got %d", got)
+	}
+}
+
+func TestAfford(t *testing.T) {
+	g := New(Options{Bankroll: 150})
+	g.deck = cards(deck.Five, deck.Ten, deck.Six, deck.Ten)
+	bet(&g, BasicAI(), true)
+	deal(&g)
+	g.deck = cards(deck.Two)
+	if err := MoveDouble(&g); err != nil {
+		t.Fatal(err)
+	}
+	if g.hands[0].bet != 150 {
+		t.Errorf("Expected to double for the 50 left. Got a bet of %d", g.hands[0].bet)
+	}
+
+	g = New(Options{Bankroll: 300, Variant: Switch})
+	bet(&g, Bettor(BasicAI(), Flat(200)), true)
+	if g.hands[0].bet != 150 || g.hands[1].bet != 150 {
+		t.Errorf("Expected the bets of both hands to fit in the bankroll. Got %+v", g.hands)
+	}
+
+	g = New(Options{Hands: 200, Bankroll: 1000, Seed: 5})
+	res := g.Session(Bettor(alwaysDouble{}, Flat(400)))
+	if 1000+res.Balance < 0 {
+		t.Errorf("Expected the bankroll never to be negative. Got %+v", res)
+	}
+}
+
+// alwaysDouble doubles every hand it can
+type alwaysDouble struct {
+	basicAI
+}
+
+func (ai alwaysDouble) Play(hand []deck.Card, dealer deck.Card) Move {
+	if len(hand) == 2 {
+		return MoveDouble
+	}
+	return MoveStand
+}
+
+func TestSessionRuin(t *testing.T) {
+	g := New(Options{Hands: 1000000, Bankroll: 500, Seed: 3})
+	res := g.Session(BasicAI())
+	if !res.Ruined {
+		t.Fatalf("Expected a small balance to be ruined. Got %+v", res)
+	}
+	if 500+res.Balance >= 100 {
+		t.Errorf("Expected the session to end when the minimum bet can't be afforded. Got %+v", res)
+	}
+}
+
+func TestKellyDeckSize(t *testing.T) {
+	g := New(Options{Variant: Spanish21})
+	if size := g.table().DeckSize; size != 48 {
+		t.Fatalf("Expected decks of 48 cards in Spanish 21. Got %d", size)
+	}
+	k := Kelly(0.5, 1).(*kelly)
+	k.Bet(true, g.table())
+	k.count, k.seen = 4, 24
+	if tc := k.trueCount(); tc != 8 {
+		t.Errorf("Expected a true count of 8 with half of the 48 cards left. Got %v", tc)
+	}
+}
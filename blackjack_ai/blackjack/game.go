@@ -2,7 +2,9 @@ package blackjack
 
 import (
 	"errors"
+	"fmt"
 	"gophercises/deck"
+	"math/rand"
 )
 
 // A Stage represents the stage of the game
@@ -19,14 +21,62 @@ type Options struct {
 	Decks           int
 	Hands           int
 	BlackjackPayout float64
+	// Variant of blackjack played. The default is the classic game.
+	Variant Variant
+	// StandSoft17 makes the dealer stand on a soft 17 instead of hitting
+	StandSoft17 bool
+	// Seed makes the sequence of shoes reproducible when it isn't zero:
+	// two games with the same seed deal the same shoes
+	Seed int64
+
+	// Table limits. MinBet defaults to 100, a MaxBet of 0 means no limit.
+	MinBet int
+	MaxBet int
+	// Bankroll is the money the player sits down with. When it is set, the
+	// session ends as soon as the player can't afford the minimum bet.
+	Bankroll int
+	// The session ends when the player has lost StopLoss or won WinGoal
+	// (when they are set)
+	StopLoss int
+	WinGoal  int
+	// SideBets offered to the AIs implementing SideBettor
+	SideBets []SideBet
+}
+
+// Table describes the money at stake when an AI places a bet
+type Table struct {
+	// Bankroll is the money the player has left, 0 when the game was
+	// created without a Bankroll: the player can then bet any amount.
+	Bankroll int
+	// Balance is the net winnings of the session
+	Balance int
+	// LastHand is the net winnings of the main bets of the previous round,
+	// without the side bets
+	LastHand int
+	MinBet   int
+	MaxBet   int
+	// DeckSize is the number of cards of each deck of the shoe: 52, or 48
+	// in Spanish 21
+	DeckSize int
+}
+
+// SessionResult describes how a session ended
+type SessionResult struct {
+	Balance int
+	Hands   int
+	// Ruined is true if the player couldn't afford the minimum bet anymore
+	Ruined   bool
+	StopLoss bool
+	WinGoal  bool
 }
 
 // New returns a new game
 func New(opts Options) Game {
 	g := Game{
 		stage:    PlayerTurn,
-		dealerAI: dealerAI{},
+		dealerAI: dealerAI{standSoft17: opts.StandSoft17},
 		balance:  0,
+		variant:  opts.Variant,
 	}
 	if opts.Decks == 0 {
 		opts.Decks = 3
@@ -35,11 +85,25 @@ func New(opts Options) Game {
 		opts.Hands = 100
 	}
 	if opts.BlackjackPayout == 0. {
-		opts.BlackjackPayout = 1.5
+		opts.BlackjackPayout = opts.Variant.blackjackPayout()
 	}
 	g.nDecks = opts.Decks
 	g.nHands = opts.Hands
 	g.blackjackPayout = opts.BlackjackPayout
+	g.seed = opts.Seed
+	if opts.MinBet == 0 {
+		opts.MinBet = 100
+	}
+	g.minBet = opts.MinBet
+	g.maxBet = opts.MaxBet
+	g.bankroll = opts.Bankroll
+	g.stopLoss = opts.StopLoss
+	g.winGoal = opts.WinGoal
+	g.sideBets = opts.SideBets
+	g.sideBetStats = make([]SideBetStats, len(opts.SideBets))
+	for i, sb := range opts.SideBets {
+		g.sideBetStats[i].Name = sb.Name
+	}
 
 	return g
 }
@@ -49,22 +113,41 @@ type Game struct {
 	nDecks          int
 	nHands          int
 	blackjackPayout float64
+	variant         Variant
+	seed            int64
+	minBet          int
+	maxBet          int
+	bankroll        int
+	stopLoss        int
+	winGoal         int
+	sideBets        []SideBet
+	sideBetStats    []SideBetStats
 
 	stage Stage
 	deck  []deck.Card
 
-	player    []deck.Card
-	playerBet int
-	balance   int
+	// The player has two hands in Blackjack Switch, one otherwise
+	hands   []hand
+	current int
+	balance int
+	// lastHand is the result of the main bets of the last round
+	lastHand int
 
 	dealer   []deck.Card
 	dealerAI AI
 }
 
+// hand is one of the hands of the player, with the amount bet on it
+type hand struct {
+	cards   []deck.Card
+	bet     int
+	doubled bool
+}
+
 func (g *Game) currentHand() *[]deck.Card {
 	switch g.stage {
 	case PlayerTurn:
-		return &g.player
+		return &g.hands[g.current].cards
 	case DealerTurn:
 		return &g.dealer
 	default:
@@ -72,46 +155,122 @@ func (g *Game) currentHand() *[]deck.Card {
 	}
 }
 
+func (g *Game) table() Table {
+	t := Table{
+		Balance:  g.balance,
+		LastHand: g.lastHand,
+		MinBet:   g.minBet,
+		MaxBet:   g.maxBet,
+		DeckSize: g.variant.deckSize(),
+	}
+	if g.bankroll > 0 {
+		t.Bankroll = g.bankroll + g.balance
+	}
+	return t
+}
+
+// available returns the money the player can still put on the table, on
+// top of the bets of the current hands, or -1 without a bankroll
+func (g *Game) available() int {
+	if g.bankroll == 0 {
+		return -1
+	}
+	left := g.bankroll + g.balance
+	for _, h := range g.hands {
+		left -= h.bet
+	}
+	return left
+}
+
 func bet(g *Game, ai AI, shuffled bool) {
-	bet := ai.Bet(shuffled)
-	if bet < 100 {
-		panic("Bet must be at least 100")
+	bet := ai.Bet(shuffled, g.table())
+	if bet < g.minBet {
+		panic(fmt.Sprintf("Bet must be at least %d", g.minBet))
+	}
+	if g.maxBet > 0 && bet > g.maxBet {
+		panic(fmt.Sprintf("Bet must be at most %d", g.maxBet))
+	}
+	n := g.variant.hands()
+	if g.bankroll > 0 && bet*n > g.bankroll+g.balance {
+		// The player can't bet more than they have on their hands
+		bet = (g.bankroll + g.balance) / n
+	}
+	g.hands = make([]hand, n)
+	for i := range g.hands {
+		g.hands[i].bet = bet
+	}
+}
+
+// over returns true if the session has to stop before the next hand
+func (g *Game) over(res *SessionResult) bool {
+	switch {
+	case g.bankroll > 0 && g.bankroll+g.balance < g.minBet*g.variant.hands():
+		res.Ruined = true
+	case g.stopLoss > 0 && g.balance <= -g.stopLoss:
+		res.StopLoss = true
+	case g.winGoal > 0 && g.balance >= g.winGoal:
+		res.WinGoal = true
+	default:
+		return false
 	}
-	g.playerBet = bet
+	return true
 }
 
 func deal(g *Game) {
-	g.player = make([]deck.Card, 0, 5)
+	for i := range g.hands {
+		g.hands[i].cards = make([]deck.Card, 0, 5)
+	}
 	g.dealer = make([]deck.Card, 0, 5)
 
 	var card deck.Card
 	for i := 0; i < 2; i++ {
-		card, g.deck = draw(g.deck)
-		g.player = append(g.player, card)
+		for h := range g.hands {
+			card, g.deck = draw(g.deck)
+			g.hands[h].cards = append(g.hands[h].cards, card)
+		}
 		card, g.deck = draw(g.deck)
 		g.dealer = append(g.dealer, card)
 	}
+	g.current = 0
 	g.stage = PlayerTurn
 }
 
-// Play a game of blackjack
+// Play a game of blackjack and returns the winnings of the player
 func (g *Game) Play(ai AI) int {
+	return g.Session(ai).Balance
+}
+
+// Session plays hands of blackjack until the number of hands is reached or
+// the bankroll, stop-loss or win goal ends the session
+func (g *Game) Session(ai AI) SessionResult {
+	var res SessionResult
 	g.deck = nil
-	minCardsLeft := 52 * g.nDecks / 3
-	for i := 0; i < g.nHands; i++ {
+	shuffle := deck.Suffle
+	if g.seed != 0 {
+		shuffle = deck.SuffleWith(rand.New(rand.NewSource(g.seed)))
+	}
+	newShoe := func() []deck.Card {
+		return deck.New(deck.Deck(g.nDecks), g.variant.filter(), shuffle)
+	}
+	minCardsLeft := len(deck.New(deck.Deck(g.nDecks), g.variant.filter())) / 3
+	for ; res.Hands < g.nHands && !g.over(&res); res.Hands++ {
 		shuffled := false
 		if len(g.deck) < minCardsLeft {
-			g.deck = deck.New(deck.Deck(g.nDecks), deck.Suffle)
+			g.deck = newShoe()
 			shuffled = true
 		}
 
 		bet(g, ai, shuffled)
 		deal(g)
+		placeSideBets(g, ai)
+		if g.variant == Switch {
+			switchCards(g, ai)
+		}
 
 		for g.stage == PlayerTurn {
-			hand := make([]deck.Card, len(g.player))
-			copy(hand, g.player)
-			move := ai.Play(hand, g.dealer[0])
+			hand := make([]deck.Card, len(g.hands[g.current].cards))
+			copy(hand, g.hands[g.current].cards)
+			move := play(g, ai, hand)
 			err := move(g)
 			if err != nil {
 				switch err {
@@ -130,7 +289,8 @@ func (g *Game) Play(ai AI) int {
 		}
 		endHand(g, ai)
 	}
-	return g.balance
+	res.Balance = g.balance
+	return res
 }
 
 var (
@@ -154,16 +314,28 @@ func MoveHit(g *Game) error {
 
 // MoveStand executes a stand action on the game
 func MoveStand(g *Game) error {
+	if g.stage == PlayerTurn && g.current < len(g.hands)-1 {
+		// Move on to the next hand of the player
+		g.current++
+		return nil
+	}
 	g.stage++
 	return nil
 }
 
-// MoveDouble executes the double action on the game
+// MoveDouble executes the double action on the game. A player who can't
+// afford to double the bet doubles for less, with the money left.
 func MoveDouble(g *Game) error {
-	if len(g.player) != 2 {
+	h := &g.hands[g.current]
+	if len(h.cards) != 2 {
 		return errors.New("Can only double on a 2 cards hand")
 	}
-	g.playerBet *= 2
+	extra := h.bet
+	if left := g.available(); left >= 0 && extra > left {
+		extra = left
+	}
+	h.bet += extra
+	h.doubled = true
 	MoveHit(g)
 	return MoveStand(g)
 }
@@ -174,16 +346,35 @@ func draw(cards []deck.Card) (deck.Card, []deck.Card) {
 }
 
 func endHand(g *Game, ai AI) {
-	pScore, dScore := Score(g.player...), Score(g.dealer...)
-	pBjack, dBjack := Blackjack(g.player...), Blackjack(g.dealer...)
-	winning := g.playerBet
+	g.lastHand = 0
+	for i, h := range g.hands {
+		g.lastHand += g.variant.settle(g, h)
+		// The dealer cards are only shown with the last hand, so that the
+		// AIs counting the cards see each of them once
+		var dealer []deck.Card
+		if i == len(g.hands)-1 {
+			dealer = g.dealer
+		}
+		ai.Results(h.cards, dealer)
+	}
+	g.balance += g.lastHand
+
+	g.hands = nil
+	g.dealer = nil
+}
+
+// settleClassic returns the winnings of a hand under the classic rules
+func settleClassic(g *Game, h hand) int {
+	pScore, dScore := Score(h.cards...), Score(g.dealer...)
+	pBjack, dBjack := Blackjack(h.cards...), Blackjack(g.dealer...)
+	winning := h.bet
 	switch {
 	case pBjack && dBjack:
 		winning = 0
 	case dBjack:
 		winning = -winning
 	case pBjack:
-		winning *= int(g.blackjackPayout)
+		winning = g.blackjackWinning(h.bet)
 	case pScore > 21:
 		winning = -winning
 	case dScore > 21:
@@ -195,11 +386,11 @@ func endHand(g *Game, ai AI) {
 	case pScore == dScore:
 		winning = 0
 	}
-	g.balance += winning
-	ai.Results(g.player, g.dealer)
+	return winning
+}
 
-	g.player = nil
-	g.dealer = nil
+func (g *Game) blackjackWinning(bet int) int {
+	return int(float64(bet) * g.blackjackPayout)
 }
 
 // Blackjack returns true if a hand is a blackjack
@@ -0,0 +1,117 @@
+package blackjack
+
+import "gophercises/deck"
+
+// Odds is the probability distribution of the final hand of the dealer
+type Odds struct {
+	// Stand[i] is the probability that the dealer ends on 17+i (without a
+	// blackjack)
+	Stand     [5]float64
+	Bust      float64
+	Blackjack float64
+}
+
+// Total returns the probability that the dealer ends on total, from 17 to 21
+func (o Odds) Total(total int) float64 {
+	if total < 17 || total > 21 {
+		return 0
+	}
+	return o.Stand[total-17]
+}
+
+func (o *Odds) add(other Odds, p float64) {
+	for i := range o.Stand {
+		o.Stand[i] += p * other.Stand[i]
+	}
+	o.Bust += p * other.Bust
+	o.Blackjack += p * other.Blackjack
+}
+
+// DealerOdds returns the exact probabilities of the final hands of the dealer
+// showing up, when the hole card and the next cards come from shoe (the cards
+// the player hasn't seen). The probabilities only sum to less than 1 if the
+// shoe can run out before the dealer is done.
+func DealerOdds(shoe []deck.Card, up deck.Card, standSoft17 bool) Odds {
+	c := oddsCalculator{
+		standSoft17: standSoft17,
+		memo:        make(map[dealerState]Odds),
+	}
+	var counts composition
+	for _, card := range shoe {
+		counts[value(card)-1]++
+	}
+	return c.odds(dealerState{
+		counts: counts,
+		hard:   uint8(value(up)),
+		ace:    up.Rank == deck.Ace,
+		cards:  1,
+	})
+}
+
+// composition counts the cards left by value: aces first, tens and faces last
+type composition [10]uint16
+
+// dealerState is everything the rest of the dealer's hand depends on
+type dealerState struct {
+	counts composition
+	// hard is the score of the hand with the aces counted as 1
+	hard uint8
+	ace  bool
+	// cards is the number of cards of the hand, only counted up to 3 since
+	// it only matters for blackjacks
+	cards uint8
+}
+
+type oddsCalculator struct {
+	standSoft17 bool
+	memo        map[dealerState]Odds
+}
+
+func (c *oddsCalculator) odds(s dealerState) Odds {
+	score, soft := int(s.hard), false
+	if s.ace && score+10 <= 21 {
+		score, soft = score+10, true
+	}
+
+	var o Odds
+	switch {
+	case score > 21:
+		o.Bust = 1
+		return o
+	case s.cards == 2 && score == 21:
+		o.Blackjack = 1
+		return o
+	// The dealer always draws a hole card
+	case s.cards >= 2 && !dealerHits(score, soft, c.standSoft17):
+		o.Stand[score-17] = 1
+		return o
+	}
+
+	if memo, ok := c.memo[s]; ok {
+		return memo
+	}
+	left := 0
+	for _, n := range s.counts {
+		left += int(n)
+	}
+	for i, n := range s.counts {
+		if n == 0 {
+			continue
+		}
+		next := s
+		next.counts[i]--
+		next.hard += uint8(i + 1)
+		next.ace = s.ace || i == 0
+		if next.cards < 3 {
+			next.cards++
+		}
+		o.add(c.odds(next), float64(n)/float64(left))
+	}
+	c.memo[s] = o
+	return o
+}
+
+// value returns the blackjack value of a card, counting aces as 1
+func value(c deck.Card) int {
+	return min(int(c.Rank), 10)
+}
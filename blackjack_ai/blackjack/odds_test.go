@@ -0,0 +1,63 @@
+package blackjack
+
+import (
+	"gophercises/deck"
+	"math"
+	"testing"
+)
+
+func TestDealerOddsSure(t *testing.T) {
+	tens := deck.New(deck.Filter(func(c deck.Card) bool {
+		return c.Rank < deck.Ten
+	}))
+
+	odds := DealerOdds(tens, deck.Card{Suit: deck.Heart, Rank: deck.Ace}, false)
+	if odds.Blackjack != 1 {
+		t.Errorf("Expected a sure blackjack with an ace and only tens left. Got %+v", odds)
+	}
+	odds = DealerOdds(tens, deck.Card{Suit: deck.Heart, Rank: deck.Six}, false)
+	if odds.Bust != 1 {
+		t.Errorf("Expected a sure bust with a six and only tens left. Got %+v", odds)
+	}
+	odds = DealerOdds(tens, deck.Card{Suit: deck.Heart, Rank: deck.Seven}, false)
+	if odds.Total(17) != 1 {
+		t.Errorf("Expected a sure 17 with a seven and only tens left. Got %+v", odds)
+	}
+}
+
+func TestDealerOddsSoft17(t *testing.T) {
+	// Ace and six make a soft 17, then the dealer draws the five of spades
+	shoe := []deck.Card{{Suit: deck.Club, Rank: deck.Six}, {Suit: deck.Spade, Rank: deck.Five}}
+	up := deck.Card{Suit: deck.Heart, Rank: deck.Ace}
+	if p := DealerOdds(shoe[:1], up, true).Total(17); p != 1 {
+		t.Errorf("Expected the dealer to stand on soft 17. Got %v", p)
+	}
+	hit := DealerOdds(shoe, up, false)
+	// Whatever the hole card, the dealer ends up with a hard 12 and no cards
+	if hit.Total(17) != 0 {
+		t.Errorf("Expected the dealer to hit soft 17. Got %+v", hit)
+	}
+}
+
+func TestDealerOddsShoe(t *testing.T) {
+	shoe := deck.New(deck.Deck(6))
+	up := deck.Card{Suit: deck.Heart, Rank: deck.Six}
+	for i, c := range shoe {
+		if c == up {
+			shoe = append(shoe[:i], shoe[i+1:]...)
+			break
+		}
+	}
+	odds := DealerOdds(shoe, up, true)
+
+	sum := odds.Bust + odds.Blackjack
+	for _, p := range odds.Stand {
+		sum += p
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("Expected the probabilities to sum to 1. Got %v", sum)
+	}
+	if odds.Bust < 0.41 || odds.Bust > 0.43 {
+		t.Errorf("Expected the dealer to bust about 42%% of the time with a six. Got %v", odds.Bust)
+	}
+}
@@ -0,0 +1,211 @@
+package blackjack
+
+import (
+	"gophercises/deck"
+	"sort"
+)
+
+// A SideBet is an optional bet placed next to the main bet and decided by the
+// first two cards of the player and of the dealer
+type SideBet struct {
+	Name string
+	// Payouts maps each winning outcome to what it pays for 1. It can be
+	// changed to match the payout table of a casino.
+	Payouts map[string]float64
+	// Evaluate returns the outcome of the initial cards, or "" if the bet is
+	// lost
+	Evaluate func(player []deck.Card, dealer []deck.Card) string
+}
+
+// SideBettor is implemented by the AIs placing side bets. SideBet returns the
+// amount bet on the side bet with the given name, 0 to skip it.
+type SideBettor interface {
+	SideBet(name string, table Table) int
+}
+
+// SideBetStats sums up the side bets placed during a game
+type SideBetStats struct {
+	Name    string
+	Bets    int
+	Wagered int
+	// Net is the money won by the player (negative when they lost)
+	Net int
+}
+
+// HouseEdge returns the proportion of the money wagered won by the house
+func (s SideBetStats) HouseEdge() float64 {
+	if s.Wagered == 0 {
+		return 0
+	}
+	return -float64(s.Net) / float64(s.Wagered)
+}
+
+// Outcomes of the side bets, used as keys of the payout tables
+const (
+	PerfectPair = "perfect pair"
+	ColoredPair = "colored pair"
+	MixedPair   = "mixed pair"
+
+	SuitedTrips   = "suited trips"
+	StraightFlush = "straight flush"
+	ThreeOfAKind  = "three of a kind"
+	Straight      = "straight"
+	Flush         = "flush"
+
+	QueenOfHeartsDealerBlackjack = "queen of hearts pair and dealer blackjack"
+	QueenOfHearts                = "queen of hearts pair"
+	Matched20                    = "matched 20"
+	Suited20                     = "suited 20"
+	Any20                        = "any 20"
+)
+
+// PerfectPairs pays when the first two cards of the player are a pair:
+// 25:1 for a perfect pair (same suit), 12:1 for a colored pair and 6:1 for a
+// mixed pair
+func PerfectPairs() SideBet {
+	return SideBet{
+		Name: "Perfect Pairs",
+		Payouts: map[string]float64{
+			PerfectPair: 25,
+			ColoredPair: 12,
+			MixedPair:   6,
+		},
+		Evaluate: func(player []deck.Card, dealer []deck.Card) string {
+			a, b := player[0], player[1]
+			switch {
+			case a.Rank != b.Rank:
+				return ""
+			case a.Suit == b.Suit:
+				return PerfectPair
+			case red(a) == red(b):
+				return ColoredPair
+			default:
+				return MixedPair
+			}
+		},
+	}
+}
+
+// TwentyOnePlusThree evaluates the two cards of the player and the up card of
+// the dealer as a three cards poker hand: 100:1 for suited trips, 40:1 for a
+// straight flush, 30:1 for three of a kind, 10:1 for a straight and 5:1 for a
+// flush
+func TwentyOnePlusThree() SideBet {
+	return SideBet{
+		Name: "21+3",
+		Payouts: map[string]float64{
+			SuitedTrips:   100,
+			StraightFlush: 40,
+			ThreeOfAKind:  30,
+			Straight:      10,
+			Flush:         5,
+		},
+		Evaluate: func(player []deck.Card, dealer []deck.Card) string {
+			return pokerHand(player[0], player[1], dealer[0])
+		},
+	}
+}
+
+// LuckyLadies pays when the first two cards of the player total 20: 1000:1
+// for two queens of hearts with a dealer blackjack, 200:1 for two queens of
+// hearts, 25:1 for a matched 20 (same rank and suit), 10:1 for a suited 20 and
+// 4:1 for any other 20
+func LuckyLadies() SideBet {
+	return SideBet{
+		Name: "Lucky Ladies",
+		Payouts: map[string]float64{
+			QueenOfHeartsDealerBlackjack: 1000,
+			QueenOfHearts:                200,
+			Matched20:                    25,
+			Suited20:                     10,
+			Any20:                        4,
+		},
+		Evaluate: func(player []deck.Card, dealer []deck.Card) string {
+			a, b := player[0], player[1]
+			queenOfHearts := deck.Card{Suit: deck.Heart, Rank: deck.Queen}
+			switch {
+			case Score(a, b) != 20:
+				return ""
+			case a == queenOfHearts && b == queenOfHearts && Blackjack(dealer...):
+				return QueenOfHeartsDealerBlackjack
+			case a == queenOfHearts && b == queenOfHearts:
+				return QueenOfHearts
+			case a == b:
+				return Matched20
+			case a.Suit == b.Suit:
+				return Suited20
+			default:
+				return Any20
+			}
+		},
+	}
+}
+
+func red(c deck.Card) bool {
+	return c.Suit == deck.Heart || c.Suit == deck.Diamond
+}
+
+// pokerHand returns the best poker combination of three cards, or "" if there
+// is none
+func pokerHand(cards ...deck.Card) string {
+	ranks := make([]int, len(cards))
+	flush := true
+	for i, c := range cards {
+		ranks[i] = int(c.Rank)
+		flush = flush && c.Suit == cards[0].Suit
+	}
+	sort.Ints(ranks)
+	trips := ranks[0] == ranks[2]
+	straight := ranks[0]+1 == ranks[1] && ranks[1]+1 == ranks[2] ||
+		// The ace is also high: Queen, King, Ace
+		ranks[0] == int(deck.Ace) && ranks[1] == int(deck.Queen) && ranks[2] == int(deck.King)
+	switch {
+	case trips && flush:
+		return SuitedTrips
+	case straight && flush:
+		return StraightFlush
+	case trips:
+		return ThreeOfAKind
+	case straight:
+		return Straight
+	case flush:
+		return Flush
+	default:
+		return ""
+	}
+}
+
+// placeSideBets asks the AI for its side bets and settles them right away,
+// since they only depend on the initial cards
+func placeSideBets(g *Game, ai AI) {
+	bettor, ok := ai.(SideBettor)
+	if !ok {
+		return
+	}
+	for i, sb := range g.sideBets {
+		amount := bettor.SideBet(sb.Name, g.table())
+		if left := g.available(); left >= 0 && amount > left {
+			// The side bets fit in the bankroll too
+			amount = left
+		}
+		if amount <= 0 {
+			continue
+		}
+		winning := -amount
+		if payout, ok := sb.Payouts[sb.Evaluate(g.hands[0].cards, g.dealer)]; ok {
+			winning = int(float64(amount) * payout)
+		}
+		g.balance += winning
+		stats := &g.sideBetStats[i]
+		stats.Bets++
+		stats.Wagered += amount
+		stats.Net += winning
+	}
+}
+
+// SideBetStats returns the statistics of every side bet of the game
+func (g *Game) SideBetStats() []SideBetStats {
+	ret := make([]SideBetStats, len(g.sideBetStats))
+	copy(ret, g.sideBetStats)
+	return ret
+}
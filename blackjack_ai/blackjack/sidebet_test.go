@@ -0,0 +1,111 @@
+package blackjack
+
+import (
+	"gophercises/deck"
+	"testing"
+)
+
+func TestSideBetEvaluate(t *testing.T) {
+	card := func(r deck.Rank, s deck.Suit) deck.Card {
+		return deck.Card{Rank: r, Suit: s}
+	}
+	tests := []struct {
+		bet    SideBet
+		player []deck.Card
+		dealer []deck.Card
+		want   string
+	}{
+		{PerfectPairs(), []deck.Card{card(deck.Five, deck.Club), card(deck.Five, deck.Club)}, nil, PerfectPair},
+		{PerfectPairs(), []deck.Card{card(deck.Five, deck.Club), card(deck.Five, deck.Spade)}, nil, ColoredPair},
+		{PerfectPairs(), []deck.Card{card(deck.Five, deck.Club), card(deck.Five, deck.Heart)}, nil, MixedPair},
+		{PerfectPairs(), []deck.Card{card(deck.Five, deck.Club), card(deck.Six, deck.Club)}, nil, ""},
+		{TwentyOnePlusThree(), []deck.Card{card(deck.Seven, deck.Heart), card(deck.Seven, deck.Heart)}, []deck.Card{card(deck.Seven, deck.Heart)}, SuitedTrips},
+		{TwentyOnePlusThree(), []deck.Card{card(deck.Queen, deck.Spade), card(deck.Ace, deck.Spade)}, []deck.Card{card(deck.King, deck.Spade)}, StraightFlush},
+		{TwentyOnePlusThree(), []deck.Card{card(deck.Seven, deck.Heart), card(deck.Seven, deck.Club)}, []deck.Card{card(deck.Seven, deck.Heart)}, ThreeOfAKind},
+		{TwentyOnePlusThree(), []deck.Card{card(deck.Three, deck.Heart), card(deck.Ace, deck.Club)}, []deck.Card{card(deck.Two, deck.Heart)}, Straight},
+		{TwentyOnePlusThree(), []deck.Card{card(deck.King, deck.Heart), card(deck.Ace, deck.Club)}, []deck.Card{card(deck.Two, deck.Heart)}, ""},
+		{TwentyOnePlusThree(), []deck.Card{card(deck.Three, deck.Heart), card(deck.Nine, deck.Heart)}, []deck.Card{card(deck.Two, deck.Heart)}, Flush},
+		{LuckyLadies(), []deck.Card{card(deck.Queen, deck.Heart), card(deck.Queen, deck.Heart)}, []deck.Card{card(deck.Ace, deck.Club), card(deck.Jack, deck.Club)}, QueenOfHeartsDealerBlackjack},
+		{LuckyLadies(), []deck.Card{card(deck.Queen, deck.Heart), card(deck.Queen, deck.Heart)}, []deck.Card{card(deck.Nine, deck.Club), card(deck.Jack, deck.Club)}, QueenOfHearts},
+		{LuckyLadies(), []deck.Card{card(deck.Ten, deck.Club), card(deck.Ten, deck.Club)}, nil, Matched20},
+		{LuckyLadies(), []deck.Card{card(deck.Ten, deck.Club), card(deck.King, deck.Club)}, nil, Suited20},
+		{LuckyLadies(), []deck.Card{card(deck.Nine, deck.Club), card(deck.Ace, deck.Heart)}, nil, Any20},
+		{LuckyLadies(), []deck.Card{card(deck.Nine, deck.Club), card(deck.Ten, deck.Heart)}, nil, ""},
+	}
+	for _, test := range tests {
+		if got := test.bet.Evaluate(test.player, test.dealer); got != test.want {
+			t.Errorf("%s with %v and %v: expected %q. Got %q", test.bet.Name, test.player, test.dealer, test.want, got)
+		}
+	}
+}
+
+type sideBetAI struct {
+	AI
+}
+
+func (ai sideBetAI) SideBet(name string, table Table) int {
+	return 10
+}
+
+func TestSideBetStats(t *testing.T) {
+	g := New(Options{
+		Hands:    2000,
+		Seed:     5,
+		SideBets: []SideBet{PerfectPairs(), TwentyOnePlusThree(), LuckyLadies()},
+	})
+	g.Play(sideBetAI{BasicAI()})
+	for _, s := range g.SideBetStats() {
+		if s.Bets != 2000 || s.Wagered != 20000 {
+			t.Errorf("%s: expected 2000 bets of 10. Got %d bets for %d", s.Name, s.Bets, s.Wagered)
+		}
+		if edge := s.HouseEdge(); edge < -1 || edge > 1 {
+			t.Errorf("%s: unexpected house edge %v", s.Name, edge)
+		}
+	}
+}
+
+func TestSideBetOutcome(t *testing.T) {
+	always := SideBet{
+		Name:     "always",
+		Payouts:  map[string]float64{"win": 20},
+		Evaluate: func(player []deck.Card, dealer []deck.Card) string { return "win" },
+	}
+	g := New(Options{SideBets: []SideBet{always}})
+	g.deck = cards(deck.Five, deck.Ten, deck.Six, deck.Ten)
+	ai := sideBetAI{BasicAI()}
+	bet(&g, ai, true)
+	deal(&g)
+	placeSideBets(&g, ai)
+	MoveStand(&g)
+	for g.stage == DealerTurn {
+		g.dealerAI.Play(g.dealer, g.dealer[0])(&g)
+	}
+	endHand(&g, ai)
+	if g.balance <= 0 {
+		t.Fatalf("Expected the side bet to make up for the lost hand. Got a balance of %d", g.balance)
+	}
+	if got := outcome(g.table()); got != -1 {
+		t.Errorf("Expected the hand to be lost despite the side bet. Got an outcome of %d", got)
+	}
+}
+
+func TestSideBetBankroll(t *testing.T) {
+	g := New(Options{Bankroll: 100, SideBets: []SideBet{PerfectPairs()}})
+	g.deck = cards(deck.Five, deck.Ten, deck.Five, deck.Ten)
+	ai := sideBetAI{BasicAI()}
+	bet(&g, ai, true)
+	deal(&g)
+	placeSideBets(&g, ai)
+	if stats := g.SideBetStats()[0]; stats.Bets != 0 || g.balance != 0 {
+		t.Errorf("Expected no side bet once the bankroll is on the table. Got %+v and a balance of %d", stats, g.balance)
+	}
+
+	g = New(Options{Hands: 200, Bankroll: 150, Seed: 5, SideBets: []SideBet{PerfectPairs(), LuckyLadies()}})
+	res := g.Session(Bettor(sideBetAI{BasicAI()}, Flat(100)))
+	if 150+res.Balance < 0 {
+		t.Errorf("Expected the bankroll never to be negative. Got %+v", res)
+	}
+	if g.SideBetStats()[0].Bets == 0 {
+		t.Error("Expected the side bets of the AI to be placed through the bettor")
+	}
+}
@@ -0,0 +1,254 @@
+package blackjack
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// A Tournament ranks several AIs by making each of them play the exact same
+// sequence of shoes (common random numbers). Differences between the results
+// then come from the strategies rather than from the cards they were dealt.
+type Tournament struct {
+	// Options used for every round. Hands is the number of hands per round
+	// and Seed is the seed of the first round.
+	Options Options
+	// Rounds is the number of independent rounds played by every AI
+	Rounds int
+
+	entrants []entrant
+}
+
+type entrant struct {
+	name  string
+	newAI func() AI
+}
+
+// Register adds an AI to the tournament. newAI is called at the beginning
+// of every round so that stateful AIs (card counters...) start fresh.
+func (t *Tournament) Register(name string, newAI func() AI) {
+	t.entrants = append(t.entrants, entrant{name: name, newAI: newAI})
+}
+
+// Run plays every round with every registered AI and returns the leaderboard
+func (t *Tournament) Run() Leaderboard {
+	rounds := t.Rounds
+	if rounds < 1 {
+		rounds = 1
+	}
+	opts := t.Options
+	if opts.Hands == 0 {
+		opts.Hands = 100
+	}
+	if opts.Seed == 0 {
+		opts.Seed = 1
+	}
+
+	// results[i][r] is the winnings per hand of entrant i during round r
+	results := make([][]float64, len(t.entrants))
+	for i, e := range t.entrants {
+		results[i] = make([]float64, rounds)
+		for r := 0; r < rounds; r++ {
+			roundOpts := opts
+			roundOpts.Seed = roundSeed(opts.Seed, r)
+			g := New(roundOpts)
+			// The session may end before opts.Hands
+			res := g.Session(e.newAI())
+			if res.Hands > 0 {
+				results[i][r] = float64(res.Balance) / float64(res.Hands)
+			}
+		}
+	}
+
+	lb := Leaderboard{Rounds: rounds, Hands: opts.Hands}
+	for i, e := range t.entrants {
+		mean, variance := meanVariance(results[i])
+		lb.Standings = append(lb.Standings, Standing{
+			Name: e.name,
+			EV:   mean,
+			// The variance of a single hand is estimated from the variance
+			// of the per-hand average of each round
+			Variance: variance * float64(opts.Hands),
+			StdErr:   math.Sqrt(variance / float64(rounds)),
+		})
+	}
+	for i := range t.entrants {
+		for j := i + 1; j < len(t.entrants); j++ {
+			lb.Comparisons = append(lb.Comparisons, compare(
+				t.entrants[i].name, t.entrants[j].name, results[i], results[j]))
+		}
+	}
+	sort.SliceStable(lb.Standings, func(i, j int) bool {
+		return lb.Standings[i].EV > lb.Standings[j].EV
+	})
+	return lb
+}
+
+// roundSeed returns the seed of the round r of a series starting at seed.
+// It skips 0, which would shuffle the round at random.
+func roundSeed(seed int64, r int) int64 {
+	ret := seed + int64(r)
+	if seed < 0 && ret >= 0 {
+		ret++
+	}
+	return ret
+}
+
+// Leaderboard holds the results of a tournament, best AI first
+type Leaderboard struct {
+	Rounds      int
+	Hands       int
+	Standings   []Standing
+	Comparisons []Comparison
+}
+
+// Standing is the result of one AI in a tournament. Amounts are expressed in
+// money won per hand.
+type Standing struct {
+	Name     string
+	EV       float64
+	Variance float64
+	StdErr   float64
+}
+
+// Comparison is a paired significance test between two AIs of a tournament.
+// Diff is EV(A) - EV(B) and PValue the two-sided probability of observing
+// such a difference if both AIs had the same EV.
+type Comparison struct {
+	A, B   string
+	Diff   float64
+	StdErr float64
+	Z      float64
+	PValue float64
+}
+
+// compare runs a paired z-test on the round results of two AIs. Pairing the
+// rounds is what makes the common random numbers useful: the luck of the
+// shoe cancels out in the difference.
+func compare(a, b string, ra, rb []float64) Comparison {
+	diffs := make([]float64, len(ra))
+	for r := range ra {
+		diffs[r] = ra[r] - rb[r]
+	}
+	mean, variance := meanVariance(diffs)
+	c := Comparison{A: a, B: b, Diff: mean, PValue: 1}
+	c.StdErr = math.Sqrt(variance / float64(len(diffs)))
+	switch {
+	case c.StdErr > 0:
+		c.Z = mean / c.StdErr
+		c.PValue = math.Erfc(math.Abs(c.Z) / math.Sqrt2)
+	case mean != 0:
+		// Every round gave the same non zero difference
+		c.Z = math.Inf(1)
+		if mean < 0 {
+			c.Z = math.Inf(-1)
+		}
+		c.PValue = 0
+	}
+	return c
+}
+
+func meanVariance(xs []float64) (mean, variance float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return mean, variance / float64(len(xs)-1)
+}
+
+// PValue returns the p-value of the comparison between two AIs, whatever
+// the order they were registered in
+func (lb Leaderboard) PValue(a, b string) (float64, bool) {
+	for _, c := range lb.Comparisons {
+		if (c.A == a && c.B == b) || (c.A == b && c.B == a) {
+			return c.PValue, true
+		}
+	}
+	return 0, false
+}
+
+// WriteCSV writes one line per AI with its rank, EV, variance, standard error
+// and the p-value of the comparison with every other AI
+func (lb Leaderboard) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"rank", "name", "rounds", "hands", "ev", "variance", "stderr"}
+	for _, s := range lb.Standings {
+		header = append(header, "p_vs_"+s.Name)
+	}
+	cw.Write(header)
+	for i, s := range lb.Standings {
+		record := []string{
+			strconv.Itoa(i + 1),
+			s.Name,
+			strconv.Itoa(lb.Rounds),
+			strconv.Itoa(lb.Hands),
+			formatFloat(s.EV),
+			formatFloat(s.Variance),
+			formatFloat(s.StdErr),
+		}
+		for _, other := range lb.Standings {
+			p, ok := lb.PValue(s.Name, other.Name)
+			if !ok {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, formatFloat(p))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteMarkdown writes the leaderboard and the pairwise comparisons as
+// Markdown tables
+func (lb Leaderboard) WriteMarkdown(w io.Writer) error {
+	p := &errWriter{w: w}
+	p.printf("## Leaderboard\n\n")
+	p.printf("%d rounds of %d hands on identical shoes.\n\n", lb.Rounds, lb.Hands)
+	p.printf("| Rank | AI | EV / hand | Variance / hand | Std. error |\n")
+	p.printf("|-----:|----|----------:|----------------:|-----------:|\n")
+	for i, s := range lb.Standings {
+		p.printf("| %d | %s | %.4f | %.2f | %.4f |\n", i+1, s.Name, s.EV, s.Variance, s.StdErr)
+	}
+	if len(lb.Comparisons) == 0 {
+		return p.err
+	}
+	p.printf("\n## Pairwise comparisons\n\n")
+	p.printf("| A | B | EV(A) - EV(B) | Std. error | z | p-value |\n")
+	p.printf("|---|---|--------------:|-----------:|--:|--------:|\n")
+	for _, c := range lb.Comparisons {
+		p.printf("| %s | %s | %.4f | %.4f | %.2f | %.4f |\n", c.A, c.B, c.Diff, c.StdErr, c.Z, c.PValue)
+	}
+	return p.err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// errWriter remembers the first error so that a sequence of writes can be
+// checked once
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *errWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
@@ -0,0 +1,84 @@
+package blackjack
+
+import (
+	"bytes"
+	"gophercises/deck"
+	"strings"
+	"testing"
+)
+
+func TestTournamentIdenticalShoes(t *testing.T) {
+	tour := Tournament{
+		Options: Options{Hands: 200, Seed: 7},
+		Rounds:  5,
+	}
+	tour.Register("first", BasicAI)
+	tour.Register("second", BasicAI)
+	lb := tour.Run()
+
+	if lb.Standings[0].EV != lb.Standings[1].EV {
+		t.Errorf("Expected the same EV for the same AI on the same shoes. Got %v and %v",
+			lb.Standings[0].EV, lb.Standings[1].EV)
+	}
+	p, ok := lb.PValue("second", "first")
+	if !ok || p != 1 {
+		t.Errorf("Expected a p-value of 1 between identical AIs. Got %v", p)
+	}
+}
+
+func TestRoundSeed(t *testing.T) {
+	seen := make(map[int64]bool)
+	for r := 0; r < 5; r++ {
+		seed := roundSeed(-2, r)
+		if seed == 0 || seen[seed] {
+			t.Errorf("Expected distinct seeds other than 0. Got %d for round %d", seed, r)
+		}
+		seen[seed] = true
+	}
+}
+
+func TestTournamentEarlyStop(t *testing.T) {
+	tour := Tournament{
+		Options: Options{Hands: 1000, Seed: 7, WinGoal: 1, StopLoss: 1},
+		Rounds:  1,
+	}
+	tour.Register("basic", BasicAI)
+	lb := tour.Run()
+	if ev := lb.Standings[0].EV; ev > -10 && ev < 10 {
+		t.Errorf("Expected the EV per hand played of a session ended by its first win or loss. Got %v", ev)
+	}
+}
+
+func TestLeaderboardExport(t *testing.T) {
+	tour := Tournament{Options: Options{Hands: 50}, Rounds: 3}
+	tour.Register("basic", BasicAI)
+	tour.Register("stand", func() AI { return standAI{} })
+	lb := tour.Run()
+
+	var csv, md bytes.Buffer
+	if err := lb.WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(csv.String(), "\n"); lines != 3 {
+		t.Errorf("Expected a header and 2 lines in the CSV. Got %d lines", lines)
+	}
+	if err := lb.WriteMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "| basic | stand |") {
+		t.Errorf("Expected the comparison of basic and stand in the Markdown. Got:\n%s", md.String())
+	}
+}
+
+// standAI always bets the minimum and never draws a card
+type standAI struct{}
+
+func (ai standAI) Play(hand []deck.Card, dealer deck.Card) Move {
+	return MoveStand
+}
+
+func (ai standAI) Bet(shuffled bool, table Table) int {
+	return table.MinBet
+}
+
+func (ai standAI) Results(hand []deck.Card, dealer []deck.Card) {}
@@ -0,0 +1,208 @@
+package blackjack
+
+import "gophercises/deck"
+
+// A Variant is a set of rules of blackjack
+type Variant uint8
+
+const (
+	// Classic blackjack, blackjack pays 3:2
+	Classic Variant = iota
+	// Spanish21 is played without the tens (face cards are kept). A player
+	// 21 always wins and some 21 get a bonus: 5 cards pay 3:2, 6 cards 2:1,
+	// 7 cards or more 3:1, and 6-7-8 or 7-7-7 pay 3:2 (2:1 suited, 3:1 in
+	// spades). Bonuses aren't paid on doubled hands.
+	Spanish21
+	// Switch deals two hands to the player, who can swap their second cards.
+	// Blackjack pays 1:1 and a dealer 22 pushes against every standing hand.
+	Switch
+	// DoubleExposure shows both cards of the dealer. Blackjack pays 1:1 and
+	// the dealer wins the ties, except for a player blackjack.
+	DoubleExposure
+)
+
+func (v Variant) String() string {
+	switch v {
+	case Classic:
+		return "Classic"
+	case Spanish21:
+		return "Spanish 21"
+	case Switch:
+		return "Blackjack Switch"
+	case DoubleExposure:
+		return "Double Exposure"
+	default:
+		return "Unknown variant"
+	}
+}
+
+// hands returns the number of hands the player plays each round
+func (v Variant) hands() int {
+	if v == Switch {
+		return 2
+	}
+	return 1
+}
+
+func (v Variant) blackjackPayout() float64 {
+	switch v {
+	case Switch, DoubleExposure:
+		return 1
+	default:
+		return 1.5
+	}
+}
+
+// filter returns the option removing the cards the variant doesn't play with
+func (v Variant) filter() func([]deck.Card) []deck.Card {
+	if v == Spanish21 {
+		return deck.Filter(func(c deck.Card) bool {
+			return c.Rank == deck.Ten
+		})
+	}
+	return func(cards []deck.Card) []deck.Card {
+		return cards
+	}
+}
+
+// deckSize returns the number of cards of a deck of the variant
+func (v Variant) deckSize() int {
+	return len(v.filter()(deck.New()))
+}
+
+// settle returns the winnings of a hand once the dealer has played
+func (v Variant) settle(g *Game, h hand) int {
+	pScore, dScore := Score(h.cards...), Score(g.dealer...)
+	pBjack, dBjack := Blackjack(h.cards...), Blackjack(g.dealer...)
+	switch v {
+	case Spanish21:
+		switch {
+		case pBjack:
+			return g.blackjackWinning(h.bet)
+		case pScore > 21 || dBjack:
+			return -h.bet
+		case pScore == 21:
+			return int(float64(h.bet) * spanishBonus(h))
+		}
+	case Switch:
+		if dScore == 22 && pScore <= 21 && !pBjack {
+			return 0
+		}
+	case DoubleExposure:
+		switch {
+		case pBjack:
+			return g.blackjackWinning(h.bet)
+		case dBjack || pScore > 21:
+			return -h.bet
+		case dScore > 21 || pScore > dScore:
+			return h.bet
+		default:
+			return -h.bet
+		}
+	}
+	return settleClassic(g, h)
+}
+
+// spanishBonus returns the payout of a 21 in Spanish 21
+func spanishBonus(h hand) float64 {
+	if h.doubled {
+		return 1
+	}
+	switch n := len(h.cards); {
+	case n >= 7:
+		return 3
+	case n == 6:
+		return 2
+	case n == 5:
+		return 1.5
+	case n == 3 && (isRanks(h.cards, deck.Six, deck.Seven, deck.Eight) ||
+		isRanks(h.cards, deck.Seven, deck.Seven, deck.Seven)):
+		suited, spades := true, true
+		for _, c := range h.cards {
+			suited = suited && c.Suit == h.cards[0].Suit
+			spades = spades && c.Suit == deck.Spade
+		}
+		switch {
+		case spades:
+			return 3
+		case suited:
+			return 2
+		default:
+			return 1.5
+		}
+	}
+	return 1
+}
+
+// isRanks returns true if the cards have exactly the given ranks, in any order
+func isRanks(cards []deck.Card, ranks ...deck.Rank) bool {
+	if len(cards) != len(ranks) {
+		return false
+	}
+	count := make(map[deck.Rank]int)
+	for _, r := range ranks {
+		count[r]++
+	}
+	for _, c := range cards {
+		count[c.Rank]--
+		if count[c.Rank] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// play asks the AI for its next move, showing it the hole card of the dealer
+// in Double Exposure if it knows how to use it
+func play(g *Game, ai AI, hand []deck.Card) Move {
+	if exposed, ok := ai.(ExposedAI); ok && g.variant == DoubleExposure {
+		dealer := make([]deck.Card, len(g.dealer))
+		copy(dealer, g.dealer)
+		return exposed.PlayExposed(hand, dealer)
+	}
+	return ai.Play(hand, g.dealer[0])
+}
+
+// switchCards swaps the second cards of the two hands of the player if the
+// AI wants to, or if it is an obvious improvement for AIs that can't decide
+func switchCards(g *Game, ai AI) {
+	first, second := g.hands[0].cards, g.hands[1].cards
+	var swap bool
+	if s, ok := ai.(SwitchAI); ok {
+		f := make([]deck.Card, len(first))
+		copy(f, first)
+		s2 := make([]deck.Card, len(second))
+		copy(s2, second)
+		swap = s.Switch(f, s2, g.dealer[0])
+	} else {
+		swap = improves(first, second)
+	}
+	if swap {
+		first[1], second[1] = second[1], first[1]
+	}
+}
+
+// improves tells whether swapping the second cards of two hands is an
+// obvious improvement
+func improves(first, second []deck.Card) bool {
+	swapped := []deck.Card{first[0], second[1]}
+	other := []deck.Card{second[0], first[1]}
+	return strength(swapped)+strength(other) > strength(first)+strength(second)
+}
+
+// strength roughly rates a two cards starting hand
+func strength(cards []deck.Card) int {
+	switch score := Score(cards...); {
+	case score == 21:
+		return 4
+	case score >= 19, score == 11:
+		return 3
+	case score == 10 || score == 18:
+		return 2
+	case score == 17 || score <= 9:
+		return 1
+	default:
+		// 12 to 16 are the hands most likely to lose
+		return 0
+	}
+}
@@ -0,0 +1,112 @@
+package blackjack
+
+import (
+	"gophercises/deck"
+	"math/rand"
+	"testing"
+)
+
+func cards(ranks ...deck.Rank) []deck.Card {
+	ret := make([]deck.Card, len(ranks))
+	for i, r := range ranks {
+		ret[i] = deck.Card{Suit: deck.Heart, Rank: r}
+	}
+	return ret
+}
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name    string
+		variant Variant
+		player  []deck.Card
+		dealer  []deck.Card
+		doubled bool
+		want    int
+	}{
+		{"classic blackjack", Classic, cards(deck.Ace, deck.King), cards(deck.Ten, deck.Nine), false, 150},
+		{"classic push", Classic, cards(deck.Ten, deck.Nine), cards(deck.King, deck.Nine), false, 0},
+		{"spanish 5 cards 21", Spanish21, cards(deck.Two, deck.Three, deck.Four, deck.Five, deck.Seven), cards(deck.King, deck.Ace), false, -100},
+		{"spanish 21 beats 21", Spanish21, cards(deck.Two, deck.Three, deck.Four, deck.Five, deck.Seven), cards(deck.King, deck.Five, deck.Six), false, 150},
+		{"spanish suited 6-7-8", Spanish21, cards(deck.Eight, deck.Six, deck.Seven), cards(deck.King, deck.Eight), false, 200},
+		{"spanish doubled 21", Spanish21, cards(deck.Five, deck.Six, deck.Queen), cards(deck.King, deck.Eight), true, 100},
+		{"switch dealer 22", Switch, cards(deck.Ten, deck.Nine), cards(deck.King, deck.Six, deck.Six), false, 0},
+		{"switch player bust", Switch, cards(deck.Ten, deck.Nine, deck.Five), cards(deck.King, deck.Six, deck.Six), false, -100},
+		{"switch blackjack", Switch, cards(deck.Ace, deck.Jack), cards(deck.King, deck.Nine), false, 100},
+		{"exposure tie", DoubleExposure, cards(deck.Ten, deck.Nine), cards(deck.King, deck.Nine), false, -100},
+		{"exposure blackjack tie", DoubleExposure, cards(deck.Ace, deck.Queen), cards(deck.Ace, deck.King), false, 100},
+	}
+	for _, test := range tests {
+		g := New(Options{Variant: test.variant})
+		g.dealer = test.dealer
+		got := test.variant.settle(&g, hand{cards: test.player, bet: 100, doubled: test.doubled})
+		if got != test.want {
+			t.Errorf("%s: expected winnings of %d. Got %d", test.name, test.want, got)
+		}
+	}
+}
+
+func TestVariantsPlay(t *testing.T) {
+	for _, v := range []Variant{Classic, Spanish21, Switch, DoubleExposure} {
+		g := New(Options{Variant: v, Hands: 500, Seed: 11})
+		res := g.Session(BasicAI())
+		if res.Hands != 500 {
+			t.Errorf("%s: expected 500 hands to be played. Got %d", v, res.Hands)
+		}
+	}
+}
+
+func TestSpanishShoe(t *testing.T) {
+	shoe := deck.New(deck.Deck(2), Spanish21.filter())
+	if len(shoe) != 2*48 {
+		t.Errorf("Expected a Spanish 21 shoe of %d cards. Got %d", 2*48, len(shoe))
+	}
+}
+
+// firstHandAI keeps the first hand it is shown
+type firstHandAI struct {
+	basicAI
+	hand []deck.Card
+}
+
+func (ai *firstHandAI) Results(hand []deck.Card, dealer []deck.Card) {
+	if ai.hand == nil {
+		ai.hand = hand
+	}
+}
+
+func TestSeededShoe(t *testing.T) {
+	ai := &firstHandAI{}
+	g := New(Options{Hands: 1, Seed: 7})
+	g.Session(ai)
+	shoe := deck.New(deck.Deck(g.nDecks), g.variant.filter(), deck.SuffleWith(rand.New(rand.NewSource(7))))
+	if len(ai.hand) < 2 || ai.hand[0] != shoe[0] || ai.hand[1] != shoe[2] {
+		t.Errorf("Expected the first hand to come from the first shoe of the seed. Got %v, shoe starting with %v", ai.hand, shoe[:3])
+	}
+}
+
+// countingAI counts the cards it is shown since the last shuffle
+type countingAI struct {
+	basicAI
+	seen int
+}
+
+func (ai *countingAI) Bet(shuffled bool, table Table) int {
+	if shuffled {
+		ai.seen = 0
+	}
+	return table.MinBet
+}
+
+func (ai *countingAI) Results(hand []deck.Card, dealer []deck.Card) {
+	ai.seen += len(hand) + len(dealer)
+}
+
+func TestSwitchCardsSeen(t *testing.T) {
+	ai := &countingAI{}
+	g := New(Options{Variant: Switch, Hands: 50, Seed: 3})
+	g.Session(ai)
+	dealt := len(deck.New(deck.Deck(g.nDecks))) - len(g.deck)
+	if ai.seen != dealt {
+		t.Errorf("Expected the AI to see the %d cards dealt once. Got %d", dealt, ai.seen)
+	}
+}
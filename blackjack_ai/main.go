@@ -1,9 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"gophercises/blackjack_ai/blackjack"
 	"gophercises/deck"
+	"io"
+	"log"
+	"os"
 )
 
 type betterAI struct {
@@ -27,21 +31,26 @@ func (ai *betterAI) Play(hand []deck.Card, dealer deck.Card) blackjack.Move {
 	}
 }
 
-func (ai *betterAI) Bet(shuffled bool) int {
-	minBet := 100
+func (ai *betterAI) Bet(shuffled bool, table blackjack.Table) int {
+	minBet := table.MinBet
 	if shuffled {
 		ai.score = 0
 		ai.seen = 0
 	}
-	trueScore := ai.score / ((52*ai.decks - ai.seen) / 52)
+	size := table.DeckSize
+	decksLeft := float64(size*ai.decks-ai.seen) / float64(size)
+	trueScore := float64(ai.score) / decksLeft
+	bet := minBet
 	switch {
 	case trueScore > 14:
-		return 1000 * minBet
+		bet = 1000 * minBet
 	case trueScore > 8:
-		return 50 * minBet
-	default:
-		return minBet
+		bet = 50 * minBet
+	}
+	if table.MaxBet > 0 && bet > table.MaxBet {
+		bet = table.MaxBet
 	}
+	return bet
 }
 
 func (ai *betterAI) Results(hand []deck.Card, dealer []deck.Card) {
@@ -65,11 +74,46 @@ func (ai *betterAI) count(c deck.Card) {
 }
 
 func main() {
-	opts := blackjack.Options{
-		Decks:           3,
-		Hands:           50000,
-		BlackjackPayout: 1.5,
+	tournament := flag.Bool("tournament", false, "rank every AI by playing them on identical shoes")
+	rounds := flag.Int("rounds", 100, "number of rounds played by each AI in a tournament")
+	hands := flag.Int("hands", 1000, "number of hands in a tournament round")
+	seed := flag.Int64("seed", 1, "seed of the first shoe of a tournament")
+	csvFile := flag.String("csv", "", "export the tournament leaderboard to this CSV file")
+	mdFile := flag.String("md", "", "export the tournament leaderboard to this Markdown file")
+	betting := flag.Bool("betting", false, "compare the risk of ruin of the betting systems")
+	sessions := flag.Int("sessions", 1000, "number of sessions played for each bankroll")
+	stopLoss := flag.Int("stoploss", 0, "end a session after losing this amount")
+	winGoal := flag.Int("wingoal", 0, "end a session after winning this amount")
+	variantName := flag.String("variant", "classic", "variant of blackjack: classic, spanish21, switch or exposure")
+	standSoft17 := flag.Bool("s17", false, "the dealer stands on soft 17")
+	sideBets := flag.Bool("sidebets", false, "estimate the house edge of the side bets")
+	flag.Parse()
+
+	variant, ok := variants[*variantName]
+	if !ok {
+		log.Fatalf("unknown variant %q", *variantName)
+	}
+	rules := blackjack.Options{
+		Decks:       3,
+		Variant:     variant,
+		StandSoft17: *standSoft17,
+	}
+
+	if *tournament {
+		runTournament(rules, *rounds, *hands, *seed, *csvFile, *mdFile)
+		return
+	}
+	if *betting {
+		runBetting(rules, *sessions, *hands, *seed, *stopLoss, *winGoal)
+		return
+	}
+	if *sideBets {
+		runSideBets(rules, *hands*(*rounds))
+		return
 	}
+
+	opts := rules
+	opts.Hands = 50000
 	g := blackjack.New(opts)
 
 	winings := g.Play(&betterAI{
@@ -80,3 +124,119 @@ func main() {
 
 	fmt.Println(winings)
 }
+
+var variants = map[string]blackjack.Variant{
+	"classic":   blackjack.Classic,
+	"spanish21": blackjack.Spanish21,
+	"switch":    blackjack.Switch,
+	"exposure":  blackjack.DoubleExposure,
+}
+
+func runTournament(rules blackjack.Options, rounds, hands int, seed int64, csvFile, mdFile string) {
+	decks := rules.Decks
+	opts := rules
+	opts.Hands = hands
+	opts.Seed = seed
+	t := blackjack.Tournament{
+		Options: opts,
+		Rounds:  rounds,
+	}
+	t.Register("basic", blackjack.BasicAI)
+	t.Register("better", func() blackjack.AI {
+		return &betterAI{decks: decks}
+	})
+
+	lb := t.Run()
+	if err := lb.WriteMarkdown(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	if csvFile != "" {
+		if err := writeFile(csvFile, lb.WriteCSV); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if mdFile != "" {
+		if err := writeFile(mdFile, lb.WriteMarkdown); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func runBetting(rules blackjack.Options, sessions, hands int, seed int64, stopLoss, winGoal int) {
+	decks := rules.Decks
+	opts := rules
+	opts.Hands = hands
+	opts.MinBet = 100
+	opts.MaxBet = 10000
+	opts.Seed = seed
+	opts.StopLoss = stopLoss
+	opts.WinGoal = winGoal
+	systems := []struct {
+		name      string
+		newSystem func() blackjack.BettingSystem
+	}{
+		{"flat", func() blackjack.BettingSystem { return blackjack.Flat(100) }},
+		{"kelly/2", func() blackjack.BettingSystem { return blackjack.Kelly(0.5, decks) }},
+		{"martingale", func() blackjack.BettingSystem { return blackjack.Martingale(100) }},
+		{"paroli", func() blackjack.BettingSystem { return blackjack.Paroli(100) }},
+		{"1-3-2-6", func() blackjack.BettingSystem { return blackjack.OneThreeTwoSix(100) }},
+	}
+	bankrolls := []int{1000, 2000, 5000, 10000, 20000, 50000}
+
+	fmt.Printf("Risk of ruin over %d sessions of %d hands\n\n", sessions, hands)
+	fmt.Printf("%-12s", "bankroll")
+	for _, b := range bankrolls {
+		fmt.Printf("%10d", b)
+	}
+	fmt.Println()
+	for _, sys := range systems {
+		newSystem := sys.newSystem
+		curve := blackjack.RuinCurve(opts, func() blackjack.AI {
+			return blackjack.Bettor(blackjack.BasicAI(), newSystem())
+		}, sessions, bankrolls)
+		fmt.Printf("%-12s", sys.name)
+		for _, p := range curve {
+			fmt.Printf("%9.1f%%", 100*p.RiskOfRuin)
+		}
+		fmt.Println()
+	}
+}
+
+// sideBetAI plays like the basic AI and places every side bet offered
+type sideBetAI struct {
+	blackjack.AI
+	amount int
+}
+
+func (ai sideBetAI) SideBet(name string, table blackjack.Table) int {
+	return ai.amount
+}
+
+func runSideBets(rules blackjack.Options, hands int) {
+	opts := rules
+	opts.Hands = hands
+	opts.SideBets = []blackjack.SideBet{
+		blackjack.PerfectPairs(),
+		blackjack.TwentyOnePlusThree(),
+		blackjack.LuckyLadies(),
+	}
+	g := blackjack.New(opts)
+	g.Play(sideBetAI{AI: blackjack.BasicAI(), amount: 10})
+
+	fmt.Printf("House edge of the side bets over %d hands\n\n", hands)
+	for _, s := range g.SideBetStats() {
+		fmt.Printf("%-14s%8.2f%%\n", s.Name, 100*s.HouseEdge())
+	}
+}
+
+func writeFile(name string, write func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -110,6 +110,17 @@ func Suffle(cards []Card) []Card {
 	return cards
 }
 
+// SuffleWith suffles the deck of cards using the given source of randomness,
+// so that the same seed always produces the same deck
+func SuffleWith(r *rand.Rand) func([]Card) []Card {
+	return func(cards []Card) []Card {
+		r.Shuffle(len(cards), func(i, j int) {
+			cards[i], cards[j] = cards[j], cards[i]
+		})
+		return cards
+	}
+}
+
 // Jokers adds two jockers to the deck of cards
 func Jokers(n int) func([]Card) []Card {
 	return func(cards []Card) []Card {
@@ -2,6 +2,7 @@ package deck
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -77,3 +78,13 @@ func TestDeck(t *testing.T) {
 		t.Errorf("Expected %d cards. Got %d", numOfDeck*52, len(cards))
 	}
 }
+
+func TestSuffleWith(t *testing.T) {
+	a := New(SuffleWith(rand.New(rand.NewSource(42))))
+	b := New(SuffleWith(rand.New(rand.NewSource(42))))
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Expected identical decks for the same seed. Got %s and %s at %d", a[i], b[i], i)
+		}
+	}
+}
@@ -20,7 +20,7 @@ import (
 	"fmt"
 	"gophercises/task/db"
 	"os"
-	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -29,18 +29,30 @@ import (
 var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add a new task to your TODO list",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Add a new task to your TODO list. Some words set the fields of the task:
+
+  +work         adds the tag work
+  p:high        sets the priority: low, medium, high, or l, m, h, or 1, 2, 3
+  due:friday    sets the due date: today, tomorrow, a day of the week,
+                a number of days or weeks like 3d or 2w, or a date like 2019-12-31
+
+For example: task add review the PR +work due:friday p:high`,
+	Args: cobra.MinimumNArgs(1),
 
 	Run: func(cmd *cobra.Command, args []string) {
-		newTask := strings.Join(args, " ")
+		newTask, err := parseTask(args, time.Now())
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
 
-		err := db.AddTask(newTask)
+		err = db.AddTask(newTask)
 		if err != nil {
 			fmt.Println(err.Error())
 			os.Exit(1)
 		}
 
-		fmt.Printf("Added \"%s\" to your tasks list\n", newTask)
+		fmt.Printf("Added \"%s\" to your tasks list\n", describe(newTask))
 	},
 }
 
@@ -0,0 +1,61 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"gophercises/task/db"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var since time.Duration
+
+// completedCmd represents the completed command
+var completedCmd = &cobra.Command{
+	Use:   "completed",
+	Short: "List the tasks you have completed, the most recent first",
+	Args:  cobra.NoArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		var from time.Time
+		if since > 0 {
+			from = time.Now().Add(-since)
+		}
+		tasks, err := db.CompletedTasks(from)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		if len(tasks) == 0 {
+			fmt.Println("You have not completed any task yet")
+		} else {
+			fmt.Println("You have completed the following tasks:")
+			for _, t := range tasks {
+				fmt.Printf("%v. %v (done %v)\n", t.Key, describe(t), t.Completed.Format("Mon 2006-01-02 15:04"))
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(completedCmd)
+	completedCmd.Flags().DurationVar(&since, "since", 0, "only list the tasks completed in this duration, like 24h")
+}
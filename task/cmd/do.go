@@ -30,51 +30,45 @@ import (
 var doCmd = &cobra.Command{
 	Use:   "do",
 	Short: "Mark a task on your TODO list as complet",
-	Args: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 1 {
-			return errors.New("requires at least 1 argument")
-		}
-		for _, id := range args {
-			if _, err := strconv.Atoi(id); err != nil {
-				return errors.New("requires integers argument, got " + id)
-			}
-		}
-		return nil
-	},
+	Args:  idArgs,
 
 	Run: func(cmd *cobra.Command, args []string) {
-		var taskIDs []int
-		for _, i := range args {
-			ID, err := strconv.Atoi(i)
-			taskIDs = append(taskIDs, ID)
-			if err != nil {
-				fmt.Println(err.Error())
-				os.Exit(1)
-			}
-		}
-
-		tasks, err := db.Tasks()
+		tasks, err := db.CompleteTasks(taskIDs(args)...)
 		if err != nil {
-			fmt.Println(err.Error())
+			fmt.Printf("Failed to mark the tasks as complete, none was. Error: %s\n", err.Error())
 			os.Exit(1)
 		}
 
-		for _, id := range taskIDs {
-			if id < 1 || id > len(tasks) {
-				fmt.Println("Invalid task number", id)
-				continue
-			}
-
-			err = db.DeleteTask(tasks[id-1].Key)
-			if err != nil {
-				fmt.Printf("Failed to mark %v as complete. Error: %s\n", id, err.Error())
-			} else {
-				fmt.Printf("You have completed the \"%s\" task.\n", tasks[id-1].Value)
-			}
+		for _, t := range tasks {
+			fmt.Printf("You have completed the \"%s\" task.\n", t.Value)
 		}
 	},
 }
 
+// idArgs checks that the arguments are task ids, as shown by the list
+// command
+func idArgs(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return errors.New("requires at least 1 argument")
+	}
+	for _, id := range args {
+		if _, err := strconv.Atoi(id); err != nil {
+			return errors.New("requires integers argument, got " + id)
+		}
+	}
+	return nil
+}
+
+// taskIDs returns the ids checked by idArgs
+func taskIDs(args []string) []int {
+	var ids []int
+	for _, arg := range args {
+		id, _ := strconv.Atoi(arg)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func init() {
 	rootCmd.AddCommand(doCmd)
 }
@@ -17,37 +17,155 @@ limitations under the License.
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"gophercises/task/db"
+	"io"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+var listFormat string
+var listSort []string
+
 // listCmd represents the list command
 var listCmd = &cobra.Command{
-	Use:   "list",
+	Use:   "list [query]",
 	Short: "List all of your incomplete tasks",
-	Args:  cobra.NoArgs,
+	Long: `List all of your incomplete tasks, or the ones matching a query:
+
+  +work         tasks tagged work
+  -home         tasks not tagged home
+  p:high        tasks of a priority, or of a range like p:>=medium
+  due:<7d       tasks due in a range, with the dates of the add command,
+                or due:none for the tasks without due date
+  milk          tasks whose text contains milk
+  sort:due      sorts by due, priority or created, or the reverse like sort:-due
+
+For example: task list +work -home due:<7d p:high sort:due
+
+The tasks are shown with their id, used by the do and rm commands.`,
+	// The flags are parsed by hand so that -tag is read as a query
+	DisableFlagParsing: true,
 
 	Run: func(cmd *cobra.Command, args []string) {
-		tasks, err := db.Tasks()
+		words, flags := splitFlags(cmd, args)
+		if err := cmd.Flags().Parse(flags); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if help, _ := cmd.Flags().GetBool("help"); help {
+			cmd.Help()
+			return
+		}
+		for _, key := range listSort {
+			words = append(words, "sort:"+key)
+		}
+		q, err := parseQuery(words, time.Now())
 		if err != nil {
 			fmt.Println(err.Error())
 			os.Exit(1)
 		}
 
-		if len(tasks) == 0 {
-			fmt.Println("You have no tasks to do ! Take a vacation 🏖")
-		} else {
-			fmt.Println("You have the following tasks:")
-			for i, t := range tasks {
-				fmt.Printf("%v. %v\n", i+1, t.Value)
-			}
+		tasks, err := db.Tasks()
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		if err := printTasks(os.Stdout, listFormat, q.apply(tasks), len(tasks)); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
 		}
 	},
 }
 
+// splitFlags separates the flags of cmd from the words of the query
+func splitFlags(cmd *cobra.Command, args []string) (words, flags []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(words, args[i+1:]...), flags
+		}
+		var f *pflag.Flag
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			f = cmd.Flags().Lookup(strings.SplitN(arg[2:], "=", 2)[0])
+		case len(arg) == 2 && arg[0] == '-':
+			f = cmd.Flags().ShorthandLookup(arg[1:])
+		}
+		if f == nil {
+			words = append(words, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		// The flags which aren't booleans take the next argument
+		if f.NoOptDefVal == "" && !strings.Contains(arg, "=") && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	return words, flags
+}
+
+// jsonTask is a task with its id in the JSON output
+type jsonTask struct {
+	ID int `json:"id"`
+	db.Task
+}
+
+// printTasks writes the tasks in a format: table, json or plain, with one
+// id and task per line
+func printTasks(w io.Writer, format string, tasks []db.Task, total int) error {
+	switch format {
+	case "json":
+		ret := make([]jsonTask, len(tasks))
+		for i, t := range tasks {
+			ret[i] = jsonTask{ID: t.Key, Task: t}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ret)
+	case "plain":
+		for _, t := range tasks {
+			fmt.Fprintf(w, "%v. %v\n", t.Key, describe(t))
+		}
+		return nil
+	case "table":
+	default:
+		return fmt.Errorf("invalid format %q, it must be table, json or plain", format)
+	}
+
+	switch {
+	case total == 0:
+		fmt.Fprintln(w, "You have no tasks to do ! Take a vacation 🏖")
+		return nil
+	case len(tasks) == 0:
+		fmt.Fprintln(w, "No task matches your query")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTASK\tPRIORITY\tDUE\tTAGS")
+	for _, t := range tasks {
+		due := ""
+		if t.Due != nil {
+			due = t.Due.Format("Mon 2006-01-02")
+		}
+		tags := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			tags[i] = "+" + tag
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", t.Key, t.Value, t.Priority, due, strings.Join(tags, " "))
+	}
+	return tw.Flush()
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVarP(&listFormat, "format", "f", "table", "output format: table, json or plain")
+	listCmd.Flags().StringSliceVarP(&listSort, "sort", "s", nil, "sort by due, priority or created, -due for the reverse")
 }
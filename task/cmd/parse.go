@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"gophercises/task/db"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTask builds a task from the words of the add command. Some words
+// set the fields of the task instead of being part of its text:
+//
+//	+work         adds the tag work
+//	p:high        sets the priority: low, medium, high, or l, m, h, or 1, 2, 3
+//	due:friday    sets the due date: today, tomorrow, a day of the week
+//	              (the next one), a number of days or weeks like 3d or 2w,
+//	              or a date like 2019-12-31
+func parseTask(args []string, now time.Time) (db.Task, error) {
+	var t db.Task
+	var words []string
+	for _, arg := range args {
+		for _, word := range strings.Fields(arg) {
+			switch {
+			case len(word) > 1 && word[0] == '+':
+				t.Tags = append(t.Tags, strings.ToLower(word[1:]))
+			case strings.HasPrefix(word, "p:") || strings.HasPrefix(word, "priority:"):
+				value := word[strings.Index(word, ":")+1:]
+				p, ok := db.ParsePriority(value)
+				if !ok {
+					return t, fmt.Errorf("invalid priority %q", value)
+				}
+				t.Priority = p
+			case strings.HasPrefix(word, "due:"):
+				due, err := parseDue(strings.TrimPrefix(word, "due:"), now)
+				if err != nil {
+					return t, err
+				}
+				t.Due = &due
+			default:
+				words = append(words, word)
+			}
+		}
+	}
+	t.Value = strings.Join(words, " ")
+	if t.Value == "" {
+		return t, fmt.Errorf("the task has no text")
+	}
+	return t, nil
+}
+
+var weekdays = map[string]time.Weekday{}
+
+func init() {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		weekdays[name] = d
+		weekdays[name[:3]] = d
+	}
+}
+
+// parseDue returns the day of a due date, at midnight
+func parseDue(s string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	s = strings.ToLower(s)
+	switch s {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	if d, ok := weekdays[s]; ok {
+		days := (int(d) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), nil
+	}
+	if n := len(s); n > 1 && (s[n-1] == 'd' || s[n-1] == 'w') {
+		if count, err := strconv.Atoi(s[:n-1]); err == nil && count >= 0 {
+			if s[n-1] == 'w' {
+				count *= 7
+			}
+			return today.AddDate(0, 0, count), nil
+		}
+	}
+	due, err := time.ParseInLocation("2006-01-02", s, now.Location())
+	if err != nil {
+		return due, fmt.Errorf("invalid due date %q", s)
+	}
+	return due, nil
+}
+
+// describe returns the text of a task followed by its fields
+func describe(t db.Task) string {
+	parts := []string{t.Value}
+	if t.Priority != db.PriorityNone {
+		parts = append(parts, "["+t.Priority.String()+"]")
+	}
+	if t.Due != nil {
+		parts = append(parts, "due "+t.Due.Format("Mon 2006-01-02"))
+	}
+	for _, tag := range t.Tags {
+		parts = append(parts, "+"+tag)
+	}
+	return strings.Join(parts, " ")
+}
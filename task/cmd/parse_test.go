@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"gophercises/task/db"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTask(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2019, 11, 13, 15, 4, 0, 0, time.Local)
+	day := func(d int) *time.Time {
+		t := time.Date(2019, 11, d, 0, 0, 0, 0, time.Local)
+		return &t
+	}
+	tests := []struct {
+		args []string
+		want db.Task
+	}{
+		{[]string{"buy", "milk"}, db.Task{Value: "buy milk"}},
+		{[]string{"review the PR", "+work", "due:friday", "p:high"},
+			db.Task{Value: "review the PR", Tags: []string{"work"}, Due: day(15), Priority: db.PriorityHigh}},
+		{[]string{"call", "mom", "due:wed", "priority:2"}, db.Task{Value: "call mom", Due: day(20), Priority: db.PriorityMedium}},
+		{[]string{"+home", "+Garden", "mow", "due:tomorrow"}, db.Task{Value: "mow", Tags: []string{"home", "garden"}, Due: day(14)}},
+		{[]string{"pay", "due:2w", "p:l"}, db.Task{Value: "pay", Due: day(27), Priority: db.PriorityLow}},
+		{[]string{"a + b", "due:2019-11-30"}, db.Task{Value: "a + b", Due: day(30)}},
+	}
+	for _, test := range tests {
+		got, err := parseTask(test.args, now)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: expected %+v. Got %+v", test.args, test.want, got)
+		}
+	}
+
+	for _, args := range [][]string{{"x", "p:urgent"}, {"x", "due:someday"}, {"+work", "p:h"}} {
+		if _, err := parseTask(args, now); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"gophercises/task/db"
+	"sort"
+	"strings"
+	"time"
+)
+
+// query selects and sorts the tasks of the list command
+type query struct {
+	filters []func(db.Task) bool
+	sorts   []func(a, b db.Task) int
+}
+
+// parseQuery reads the words of a query. Every filter must match:
+//
+//	+work         tasks tagged work
+//	-home         tasks not tagged home
+//	p:high        tasks of a priority, or of a range with <, <=, > or >=
+//	              like p:>=medium
+//	due:<7d       tasks due in a range, with the dates of the add command,
+//	              or due:none for the tasks without due date
+//	milk          tasks whose text contains milk
+//	sort:due      sorts by due, priority or created, or the reverse with a
+//	              minus like sort:-priority; several sorts can be given
+//
+// The tasks without due date come last when sorted by due date, the
+// highest priorities come first.
+func parseQuery(args []string, now time.Time) (query, error) {
+	var q query
+	for _, arg := range args {
+		for _, word := range strings.Fields(arg) {
+			if err := q.add(word, now); err != nil {
+				return q, err
+			}
+		}
+	}
+	return q, nil
+}
+
+func (q *query) add(word string, now time.Time) error {
+	switch {
+	case len(word) > 1 && word[0] == '+':
+		tag := strings.ToLower(word[1:])
+		q.filters = append(q.filters, func(t db.Task) bool { return hasTag(t, tag) })
+	case len(word) > 1 && word[0] == '-':
+		tag := strings.ToLower(word[1:])
+		q.filters = append(q.filters, func(t db.Task) bool { return !hasTag(t, tag) })
+	case strings.HasPrefix(word, "p:") || strings.HasPrefix(word, "priority:"):
+		op, value := operator(word[strings.Index(word, ":")+1:])
+		p, ok := db.ParsePriority(value)
+		if !ok {
+			return fmt.Errorf("invalid priority %q", value)
+		}
+		q.filters = append(q.filters, func(t db.Task) bool { return op(int(t.Priority) - int(p)) })
+	case word == "due:none":
+		q.filters = append(q.filters, func(t db.Task) bool { return t.Due == nil })
+	case strings.HasPrefix(word, "due:"):
+		op, value := operator(strings.TrimPrefix(word, "due:"))
+		due, err := parseDue(value, now)
+		if err != nil {
+			return err
+		}
+		q.filters = append(q.filters, func(t db.Task) bool {
+			return t.Due != nil && op(compareTimes(*t.Due, due))
+		})
+	case strings.HasPrefix(word, "sort:"):
+		key := strings.TrimPrefix(word, "sort:")
+		reverse := strings.HasPrefix(key, "-")
+		cmp, ok := sorts[strings.TrimPrefix(key, "-")]
+		if !ok {
+			return fmt.Errorf("invalid sort %q, it must be due, priority or created", key)
+		}
+		if reverse {
+			q.sorts = append(q.sorts, func(a, b db.Task) int { return cmp(b, a) })
+		} else {
+			q.sorts = append(q.sorts, cmp)
+		}
+	default:
+		text := strings.ToLower(word)
+		q.filters = append(q.filters, func(t db.Task) bool {
+			return strings.Contains(strings.ToLower(t.Value), text)
+		})
+	}
+	return nil
+}
+
+// apply returns the tasks matching the filters, sorted
+func (q query) apply(tasks []db.Task) []db.Task {
+	ret := []db.Task{}
+next:
+	for _, t := range tasks {
+		for _, match := range q.filters {
+			if !match(t) {
+				continue next
+			}
+		}
+		ret = append(ret, t)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		for _, cmp := range q.sorts {
+			if c := cmp(ret[i], ret[j]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return ret
+}
+
+var sorts = map[string]func(a, b db.Task) int{
+	"due": func(a, b db.Task) int {
+		switch {
+		case a.Due == nil && b.Due == nil:
+			return 0
+		case a.Due == nil:
+			return 1
+		case b.Due == nil:
+			return -1
+		}
+		return compareTimes(*a.Due, *b.Due)
+	},
+	"priority": func(a, b db.Task) int {
+		return int(b.Priority) - int(a.Priority)
+	},
+	"created": func(a, b db.Task) int {
+		return compareTimes(a.Created, b.Created)
+	},
+}
+
+// operator splits the comparison operator of a value, = by default. The
+// returned function tells whether the result of a comparison matches it.
+func operator(s string) (func(c int) bool, string) {
+	for _, op := range []struct {
+		prefix string
+		match  func(c int) bool
+	}{
+		{"<=", func(c int) bool { return c <= 0 }},
+		{">=", func(c int) bool { return c >= 0 }},
+		{"<", func(c int) bool { return c < 0 }},
+		{">", func(c int) bool { return c > 0 }},
+		{"=", func(c int) bool { return c == 0 }},
+	} {
+		if strings.HasPrefix(s, op.prefix) {
+			return op.match, strings.TrimPrefix(s, op.prefix)
+		}
+	}
+	return func(c int) bool { return c == 0 }, s
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func hasTag(t db.Task, tag string) bool {
+	for _, other := range t.Tags {
+		if other == tag {
+			return true
+		}
+	}
+	return false
+}
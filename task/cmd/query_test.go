@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"gophercises/task/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2019, 11, 13, 15, 4, 0, 0, time.Local)
+	var tasks []db.Task
+	for _, add := range []string{
+		"write report +work due:friday p:high",
+		"buy milk +home",
+		"fix the bike +home +work due:2w p:low",
+		"call the bank due:today p:m",
+		"read a book",
+	} {
+		task, err := parseTask([]string{add}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		task.Key = len(tasks) + 1
+		task.Created = now.Add(time.Duration(len(tasks)) * time.Minute)
+		tasks = append(tasks, task)
+	}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{1, 2, 3, 4, 5}},
+		{"+work", []int{1, 3}},
+		{"+work -home", []int{1}},
+		{"-home", []int{1, 4, 5}},
+		{"due:<7d", []int{1, 4}},
+		{"due:<=friday", []int{1, 4}},
+		{"due:none", []int{2, 5}},
+		{"p:high", []int{1}},
+		{"p:>=medium", []int{1, 4}},
+		{"p:<m", []int{2, 3, 5}},
+		{"BIKE", []int{3}},
+		{"sort:due", []int{4, 1, 3, 2, 5}},
+		{"sort:-due", []int{2, 5, 3, 1, 4}},
+		{"sort:priority", []int{1, 4, 3, 2, 5}},
+		{"sort:-created", []int{5, 4, 3, 2, 1}},
+		{"-work sort:priority sort:-created", []int{4, 5, 2}},
+		{"+work -home due:<7d p:high sort:due", []int{1}},
+	}
+	for _, test := range tests {
+		q, err := parseQuery(strings.Fields(test.query), now)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.query, err)
+			continue
+		}
+		var got []int
+		for _, task := range q.apply(tasks) {
+			got = append(got, task.Key)
+		}
+		if !equalInts(got, test.want) {
+			t.Errorf("%q: expected tasks %v. Got %v", test.query, test.want, got)
+		}
+	}
+
+	for _, query := range []string{"p:urgent", "due:<soon", "sort:name"} {
+		if _, err := parseQuery([]string{query}, now); err == nil {
+			t.Errorf("%q: expected an error", query)
+		}
+	}
+}
+
+func TestPrintTasks(t *testing.T) {
+	due := time.Date(2019, 11, 15, 0, 0, 0, 0, time.UTC)
+	tasks := []db.Task{
+		{Key: 3, Value: "write report", Priority: db.PriorityHigh, Due: &due, Tags: []string{"work"}},
+		{Key: 1, Value: "buy milk"},
+	}
+
+	var b bytes.Buffer
+	if err := printTasks(&b, "plain", tasks, 3); err != nil {
+		t.Fatal(err)
+	}
+	if want := "3. write report [high] due Fri 2019-11-15 +work\n1. buy milk\n"; b.String() != want {
+		t.Errorf("Expected %q. Got %q", want, b.String())
+	}
+
+	b.Reset()
+	if err := printTasks(&b, "json", tasks, 3); err != nil {
+		t.Fatal(err)
+	}
+	var got []struct {
+		ID    int    `json:"id"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil || len(got) != 2 || got[0].ID != 3 || got[1].Value != "buy milk" {
+		t.Errorf("Expected the tasks and their ids in JSON. Got %s", b.String())
+	}
+
+	b.Reset()
+	if err := printTasks(&b, "table", tasks, 3); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "3   write report  high") || !strings.HasSuffix(lines[1], "+work") {
+		t.Errorf("Expected a table of the tasks. Got\n%s", b.String())
+	}
+
+	if err := printTasks(&b, "xml", tasks, 3); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
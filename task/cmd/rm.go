@@ -0,0 +1,48 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"gophercises/task/db"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// rmCmd represents the rm command
+var rmCmd = &cobra.Command{
+	Use:   "rm",
+	Short: "Delete a task from your TODO list without completing it",
+	Args:  idArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		tasks, err := db.DeleteTasks(taskIDs(args)...)
+		if err != nil {
+			fmt.Printf("Failed to delete the tasks, none was. Error: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		for _, t := range tasks {
+			fmt.Printf("You have deleted the \"%s\" task.\n", t.Value)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rmCmd)
+}
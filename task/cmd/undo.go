@@ -0,0 +1,48 @@
+/*
+Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"gophercises/task/db"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Restore a completed task to your TODO list",
+	Args:  idArgs,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		tasks, err := db.UndoTasks(taskIDs(args)...)
+		if err != nil {
+			fmt.Printf("Failed to restore the tasks, none was. Error: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		for _, t := range tasks {
+			fmt.Printf("The \"%s\" task is back in your tasks list.\n", t.Value)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+}
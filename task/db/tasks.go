@@ -2,21 +2,81 @@ package db
 
 import (
 	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/boltdb/bolt"
 )
 
 var tasksBucket = []byte("tasks")
+var completedBucket = []byte("completed")
+var metaBucket = []byte("meta")
+var versionKey = []byte("version")
+
+// version is the format of the tasks: the version 0 stored them as plain
+// strings, the version 1 as JSON records
+const version = 1
+
 var db *bolt.DB
 
+// ErrNotFound is returned for a task which doesn't exist
+var ErrNotFound = errors.New("task not found")
+
+// Priority of a task, the higher the more urgent
+type Priority int
+
+// The priorities of the tasks
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+var priorityNames = []string{"", "low", "medium", "high"}
+
+func (p Priority) String() string {
+	if p < PriorityNone || p > PriorityHigh {
+		return ""
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority reads a priority from its name, its first letter or its
+// number: high, h or 3 for a high priority
+func ParsePriority(s string) (Priority, bool) {
+	s = strings.ToLower(s)
+	for p, name := range priorityNames {
+		if name != "" && (s == name || s == name[:1] || s == string(rune('0'+p))) {
+			return Priority(p), true
+		}
+	}
+	if s == "med" {
+		return PriorityMedium, true
+	}
+	return PriorityNone, false
+}
+
 // Task represent a simple task
 type Task struct {
-	Key   int
-	Value string
+	// Key is the id of the task, from the sequence of the tasks bucket.
+	// It doesn't change when the task is completed.
+	Key      int        `json:"-"`
+	Value    string     `json:"value"`
+	Priority Priority   `json:"priority,omitempty"`
+	Due      *time.Time `json:"due,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`
+	Created  time.Time  `json:"created"`
+	// Completed is set once the task is done
+	Completed *time.Time `json:"completed,omitempty"`
 }
 
-// Init the database connexion and create a bucket for the task if doesn't exist
+// Init the database connexion and create a bucket for the task if doesn't exist.
+// The tasks of an older format are migrated.
 func Init(dbpath string) error {
 	// Open the database
 	var err error
@@ -24,49 +84,190 @@ func Init(dbpath string) error {
 	if err != nil {
 		return err
 	}
-	// Create the TasksBucket if it doesn't exist
+	// Create the buckets if they don't exist
 	return db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(tasksBucket)
-		return err
+		for _, name := range [][]byte{tasksBucket, completedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return migrate(tx)
 	})
 
 }
 
+// migrate converts the tasks stored as plain strings into records
+func migrate(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+	if v := meta.Get(versionKey); v != nil && btoi(v) >= version {
+		return nil
+	}
+	b := tx.Bucket(tasksBucket)
+	now := time.Now()
+	var keys, values [][]byte
+	err = b.ForEach(func(k, v []byte) error {
+		t, err := json.Marshal(Task{Value: string(v), Created: now})
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+		values = append(values, t)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The bucket can't be changed while iterating over it
+	for i, k := range keys {
+		if err := b.Put(k, values[i]); err != nil {
+			return err
+		}
+	}
+	return meta.Put(versionKey, itob(version))
+}
+
 // AddTask to the tasks list
-func AddTask(s string) error {
+func AddTask(t Task) error {
+	if t.Created.IsZero() {
+		t.Created = time.Now()
+	}
+	v, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
 	return db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(tasksBucket)
 		id, _ := b.NextSequence()
-		err := b.Put(itob(int(id)), []byte(s))
+		err := b.Put(itob(int(id)), v)
 		return err
 	})
 }
 
-// DeleteTask removes the task corresponding to the id
-func DeleteTask(id int) error {
-	return db.Update(func(tx *bolt.Tx) error {
+// DeleteTasks removes the tasks corresponding to the ids, and returns
+// them. Either all of them are removed, or none when one doesn't exist.
+func DeleteTasks(ids ...int) ([]Task, error) {
+	var ret []Task
+	err := db.Update(func(tx *bolt.Tx) error {
+		ret = nil
 		b := tx.Bucket(tasksBucket)
-		return b.Delete(itob(id))
+		for _, id := range unique(ids) {
+			t, err := takeTask(b, id)
+			if err != nil {
+				return err
+			}
+			ret = append(ret, t)
+		}
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CompleteTasks moves the tasks corresponding to the ids to the completed
+// tasks, and returns them. Either all of them are completed, or none when
+// one doesn't exist.
+func CompleteTasks(ids ...int) ([]Task, error) {
+	now := time.Now()
+	return moveTasks(tasksBucket, completedBucket, ids, &now)
+}
+
+// UndoTasks moves back the completed tasks corresponding to the ids to the
+// tasks list, and returns them. Either all of them are restored, or none
+// when one doesn't exist.
+func UndoTasks(ids ...int) ([]Task, error) {
+	return moveTasks(completedBucket, tasksBucket, ids, nil)
+}
+
+// moveTasks moves tasks between two buckets in a single transaction,
+// setting their completion time
+func moveTasks(from, to []byte, ids []int, completed *time.Time) ([]Task, error) {
+	var ret []Task
+	err := db.Update(func(tx *bolt.Tx) error {
+		ret = nil
+		for _, id := range unique(ids) {
+			t, err := takeTask(tx.Bucket(from), id)
+			if err != nil {
+				return err
+			}
+			t.Completed = completed
+			if err := putTask(tx.Bucket(to), t); err != nil {
+				return err
+			}
+			ret = append(ret, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// takeTask removes a task from a bucket and returns it. Its key, the id of
+// the task, is kept so that an undone task gets back its id and its place
+// in the list.
+func takeTask(b *bolt.Bucket, id int) (Task, error) {
+	var t Task
+	v := b.Get(itob(id))
+	if v == nil {
+		return t, notFound(id)
+	}
+	if err := json.Unmarshal(v, &t); err != nil {
+		return t, err
+	}
+	t.Key = id
+	return t, b.Delete(itob(id))
+}
+
+func putTask(b *bolt.Bucket, t Task) error {
+	v, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(t.Key), v)
 }
 
 // Tasks gives a list of all tasks
 func Tasks() ([]Task, error) {
+	return tasks(tasksBucket)
+}
+
+// CompletedTasks gives the tasks completed since a time, the most recent
+// first
+func CompletedTasks(since time.Time) ([]Task, error) {
+	all, err := tasks(completedBucket)
 	var ret []Task
+	for _, t := range all {
+		if !t.Completed.Before(since) {
+			ret = append(ret, t)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Completed.After(*ret[j].Completed)
+	})
+	return ret, err
+}
 
-	return ret, db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(tasksBucket)
-		b.ForEach(func(k, v []byte) error {
-			{
-				ret = append(ret, Task{
-					Key:   btoi(k),
-					Value: string(v),
-				})
-				return nil
+func tasks(bucket []byte) ([]Task, error) {
+	var ret []Task
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		return b.ForEach(func(k, v []byte) error {
+			var t Task
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
 			}
+			t.Key = btoi(k)
+			ret = append(ret, t)
+			return nil
 		})
-		return nil
 	})
+	return ret, err
 }
 
 // Close the database
@@ -74,6 +275,23 @@ func Close() {
 	db.Close()
 }
 
+func notFound(id int) error {
+	return fmt.Errorf("%w: %d", ErrNotFound, id)
+}
+
+// unique returns the ids without the duplicates, in order
+func unique(ids []int) []int {
+	seen := make(map[int]bool)
+	var ret []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			ret = append(ret, id)
+		}
+	}
+	return ret
+}
+
 func itob(v int) []byte {
 	b := make([]byte, 8)
 	binary.BigEndian.PutUint64(b, uint64(v))
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbpath := filepath.Join(dir, "tasks.db")
+
+	// The tasks of the first version were plain strings
+	old, err := bolt.Open(dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = old.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket(tasksBucket)
+		if err != nil {
+			return err
+		}
+		for _, v := range []string{"buy milk", `{"not": "json"`} {
+			id, _ := b.NextSequence()
+			if err := b.Put(itob(int(id)), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	old.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Init(dbpath); err != nil {
+		t.Fatal(err)
+	}
+	due := time.Date(2019, 11, 15, 0, 0, 0, 0, time.UTC)
+	if err := AddTask(Task{Value: "review", Priority: PriorityHigh, Due: &due, Tags: []string{"work"}}); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+
+	// The migration only runs once
+	if err := Init(dbpath); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	tasks, err := Tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 3 {
+		t.Fatalf("Expected 3 tasks. Got %+v", tasks)
+	}
+	if tasks[0].Value != "buy milk" || tasks[1].Value != `{"not": "json"` || tasks[0].Created.IsZero() {
+		t.Errorf("Expected the plain tasks to be migrated. Got %+v", tasks[:2])
+	}
+	if r := tasks[2]; r.Key != 3 || r.Value != "review" || r.Priority != PriorityHigh || !r.Due.Equal(due) || len(r.Tags) != 1 {
+		t.Errorf("Expected the new task to be kept. Got %+v", r)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := Init(filepath.Join(dir, "tasks.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	for _, v := range []string{"a", "b", "c"} {
+		if err := AddTask(Task{Value: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	if _, err := CompleteTasks(1, 2, 4); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when completing a missing task. Got %v", err)
+	}
+	if tasks, _ := Tasks(); len(tasks) != 3 {
+		t.Errorf("Expected no task to be completed when one is missing. Got %+v", tasks)
+	}
+	done, err := CompleteTasks(1, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || done[0].Key != 1 || done[1].Key != 2 || done[0].Completed == nil {
+		t.Errorf("Expected a and b to be completed once. Got %+v", done)
+	}
+	if _, err := CompleteTasks(2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when completing a task twice. Got %v", err)
+	}
+
+	tasks, _ := Tasks()
+	if len(tasks) != 1 || tasks[0].Value != "c" || tasks[0].Key != 3 {
+		t.Errorf("Expected only c to be left with its id. Got %+v", tasks)
+	}
+	done, err = CompletedTasks(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || done[0].Completed == nil {
+		t.Errorf("Expected a and b to be completed. Got %+v", done)
+	}
+	if done, _ := CompletedTasks(time.Now().Add(time.Hour)); len(done) != 0 {
+		t.Errorf("Expected no task completed in the future. Got %+v", done)
+	}
+
+	// An undone task gets back its id and its place
+	if _, err := UndoTasks(1, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when restoring a task which isn't completed. Got %v", err)
+	}
+	if _, err := UndoTasks(1); err != nil {
+		t.Fatal(err)
+	}
+	tasks, _ = Tasks()
+	if len(tasks) != 2 || tasks[0].Value != "a" || tasks[0].Key != 1 || tasks[0].Completed != nil {
+		t.Errorf("Expected a to be restored first. Got %+v", tasks)
+	}
+
+	if _, err := DeleteTasks(3, 5); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when deleting a missing task. Got %v", err)
+	}
+	deleted, err := DeleteTasks(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Value != "c" {
+		t.Errorf("Expected c to be deleted. Got %+v", deleted)
+	}
+	if done, _ := CompletedTasks(time.Time{}); len(done) != 1 {
+		t.Errorf("Expected a deleted task not to be completed. Got %+v", done)
+	}
+
+	// The ids aren't reused
+	AddTask(Task{Value: "d"})
+	tasks, _ = Tasks()
+	if last := tasks[len(tasks)-1]; last.Value != "d" || last.Key != 4 {
+		t.Errorf("Expected d to get a new id. Got %+v", last)
+	}
+}
@@ -0,0 +1,299 @@
+package urlshort
+
+import (
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdminPrefix is the path under which AdminHandler serves the admin UI
+const AdminPrefix = "/admin"
+
+// AdminOptions configure the admin UI
+type AdminOptions struct {
+	// TemplateDir is the directory of the templates, "tmpl/" if empty
+	TemplateDir string
+	// User and Password are the credentials of the basic auth
+	User, Password string
+	// Token is accepted as a bearer token, or as the basic auth
+	// password of any user
+	Token string
+	// API are the options used to create the links
+	API APIOptions
+}
+
+// formTime is the format of the datetime-local inputs
+const formTime = "2006-01-02T15:04"
+
+type adminLink struct {
+	Link
+	Clicks int
+	State  string
+}
+
+type listPage struct {
+	Query string
+	Links []adminLink
+}
+
+type editPage struct {
+	Link
+	New   bool
+	Error string
+}
+
+// AdminHandler returns an http.Handler serving an HTML interface to
+// manage the links of the store:
+//
+//	GET  /admin/                 lists the links, filtered by the q parameter
+//	GET  /admin/edit?path=/abc   edits a link, or creates one without path
+//	POST /admin/save             saves the form of the edit page
+//	POST /admin/delete           deletes the link of the path field
+//
+// Every request must be authenticated with the credentials of the
+// options, which must define either a user and a password or a token.
+func AdminHandler(s *Store, opts AdminOptions) (http.Handler, error) {
+	if opts.Token == "" && (opts.User == "" || opts.Password == "") {
+		return nil, errors.New("the admin UI needs a user and a password or a token")
+	}
+	dir := opts.TemplateDir
+	if dir == "" {
+		dir = "tmpl/"
+	}
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"formTime": func(t *time.Time) string {
+			if t == nil {
+				return ""
+			}
+			return t.Local().Format(formTime)
+		},
+	}).ParseFiles(dir+"list.html", dir+"edit.html")
+	if err != nil {
+		return nil, err
+	}
+	a := &admin{store: s, opts: opts, templates: templates}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="url-shortener admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost && !sameOrigin(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		switch strings.TrimPrefix(r.URL.Path, AdminPrefix) {
+		case "", "/":
+			a.list(w, r)
+		case "/edit":
+			a.edit(w, r)
+		case "/save":
+			a.save(w, r)
+		case "/delete":
+			a.delete(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}), nil
+}
+
+type admin struct {
+	store     *Store
+	opts      AdminOptions
+	templates *template.Template
+}
+
+func (a *admin) authorized(r *http.Request) bool {
+	if a.opts.Token != "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") &&
+			equal(strings.TrimPrefix(auth, "Bearer "), a.opts.Token) {
+			return true
+		}
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	if a.opts.Token != "" && equal(password, a.opts.Token) {
+		return true
+	}
+	return a.opts.User != "" && a.opts.Password != "" &&
+		equal(user, a.opts.User) && equal(password, a.opts.Password)
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// sameOrigin rejects the forms posted from other sites, which the
+// browser would send with the credentials of the user
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Referer()
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func (a *admin) render(w http.ResponseWriter, tmpl string, data interface{}) {
+	err := a.templates.ExecuteTemplate(w, tmpl+".html", data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (a *admin) list(w http.ResponseWriter, r *http.Request) {
+	links, err := a.store.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	counts, err := a.store.ClickCounts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := listPage{Query: strings.TrimSpace(r.FormValue("q"))}
+	query := strings.ToLower(page.Query)
+	now := time.Now()
+	for _, l := range links {
+		if query != "" && !strings.Contains(strings.ToLower(l.Path+" "+l.URL), query) {
+			continue
+		}
+		state := "active"
+		switch l.status(now, l.Served) {
+		case pending:
+			state = "pending"
+		case gone:
+			state = "expired"
+		}
+		if l.Health != nil && l.Health.Broken {
+			state += ", broken"
+		}
+		page.Links = append(page.Links, adminLink{Link: l, Clicks: counts[l.Path], State: state})
+	}
+	sort.Slice(page.Links, func(i, j int) bool {
+		return page.Links[i].Path < page.Links[j].Path
+	})
+	a.render(w, "list", page)
+}
+
+func (a *admin) edit(w http.ResponseWriter, r *http.Request) {
+	path := r.FormValue("path")
+	if path == "" {
+		a.render(w, "edit", editPage{New: true})
+		return
+	}
+	l, err := a.store.Get(path)
+	if err == ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.render(w, "edit", editPage{Link: l})
+}
+
+func (a *admin) save(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	page := editPage{New: r.FormValue("new") != ""}
+	err := a.parseForm(r, &page.Link)
+	if err == nil {
+		err = a.saveLink(&page.Link, page.New)
+	}
+	if err != nil {
+		page.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		a.render(w, "edit", page)
+		return
+	}
+	http.Redirect(w, r, AdminPrefix+"/?q="+url.QueryEscape(page.Path), http.StatusSeeOther)
+}
+
+func (a *admin) parseForm(r *http.Request, l *Link) error {
+	l.Path = strings.TrimSpace(r.FormValue("path"))
+	if l.Path != "" && !strings.HasPrefix(l.Path, "/") {
+		l.Path = "/" + l.Path
+	}
+	l.URL = strings.TrimSpace(r.FormValue("url"))
+	var err error
+	if l.ExpiresAt, err = parseFormTime(r.FormValue("expires_at")); err != nil {
+		return err
+	}
+	if l.NotBefore, err = parseFormTime(r.FormValue("not_before")); err != nil {
+		return err
+	}
+	if n := strings.TrimSpace(r.FormValue("max_clicks")); n != "" {
+		if l.MaxClicks, err = strconv.Atoi(n); err != nil {
+			return errors.New("max clicks must be a number")
+		}
+	}
+	if n := r.FormValue("status"); n != "" {
+		if l.Status, err = strconv.Atoi(n); err != nil {
+			return errors.New("invalid status " + strconv.Quote(n))
+		}
+	}
+	l.CacheControl = strings.TrimSpace(r.FormValue("cache_control"))
+	return l.validate()
+}
+
+func parseFormTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(formTime, value, time.Local)
+	if err != nil {
+		return nil, errors.New("invalid time " + strconv.Quote(value))
+	}
+	return &t, nil
+}
+
+func (a *admin) saveLink(l *Link, created bool) error {
+	if !created {
+		return a.store.Update(*l)
+	}
+	if l.Path == "" {
+		link, _, err := a.store.Shorten(*l, a.opts.API)
+		if err != nil {
+			return err
+		}
+		*l = link
+		return nil
+	}
+	if err := validateAlias(l.Path, a.opts.API); err != nil {
+		return err
+	}
+	return a.store.Create(*l)
+}
+
+func (a *admin) delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	err := a.store.Delete(r.FormValue("path"))
+	if err != nil && err != ErrNotFound {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, AdminPrefix+"/", http.StatusSeeOther)
+}
@@ -0,0 +1,73 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	s := testStore(t)
+	if _, err := AdminHandler(s, AdminOptions{User: "admin"}); err == nil {
+		t.Error("Expected an error without credentials")
+	}
+	h, err := AdminHandler(s, AdminOptions{User: "admin", Password: "secret", Token: "t0ken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(method, target string, form url.Values, auth func(r *http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		if form != nil {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		auth(r)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	basic := func(r *http.Request) { r.SetBasicAuth("admin", "secret") }
+
+	if w := send("GET", "/admin/", nil, func(r *http.Request) {}); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an anonymous request to be unauthorized. Got status %d", w.Code)
+	}
+	if w := send("GET", "/admin/", nil, func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong password to be unauthorized. Got status %d", w.Code)
+	}
+	if w := send("GET", "/admin/", nil, func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") }); w.Code != http.StatusOK {
+		t.Errorf("Expected the token to be accepted. Got status %d", w.Code)
+	}
+
+	w := send("POST", "/admin/save", url.Values{"new": {"1"}, "path": {"go"}, "url": {"https://golang.org"}, "max_clicks": {"3"}}, basic)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected the link to be created. Got status %d: %s", w.Code, w.Body)
+	}
+	if l, err := s.Get("/go"); err != nil || l.MaxClicks != 3 {
+		t.Errorf("Expected /go to be stored with 3 max clicks. Got %+v, %v", l, err)
+	}
+	w = send("POST", "/admin/save", url.Values{"new": {"1"}, "path": {"/bad"}, "url": {"golang.org"}}, basic)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "golang.org") {
+		t.Errorf("Expected the form to be shown again with an error. Got status %d", w.Code)
+	}
+	w = send("POST", "/admin/delete", url.Values{"path": {"/go"}}, func(r *http.Request) {
+		basic(r)
+		r.Header.Set("Origin", "https://evil.example.com")
+	})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected a cross-site post to be forbidden. Got status %d", w.Code)
+	}
+
+	w = send("GET", "/admin/?q=golang", nil, basic)
+	if !strings.Contains(w.Body.String(), `href="/admin/edit?path=%2fgo"`) {
+		t.Errorf("Expected the list to link to the edit page of /go. Got %s", w.Body)
+	}
+	w = send("POST", "/admin/save", url.Values{"path": {"/go"}, "url": {"https://go.dev"}}, basic)
+	if l, _ := s.Get("/go"); w.Code != http.StatusSeeOther || l.URL != "https://go.dev" || l.MaxClicks != 0 {
+		t.Errorf("Expected /go to be updated. Got status %d and %+v", w.Code, l)
+	}
+	send("POST", "/admin/delete", url.Values{"path": {"/go"}}, basic)
+	if _, err := s.Get("/go"); err != ErrNotFound {
+		t.Errorf("Expected /go to be deleted. Got %v", err)
+	}
+}
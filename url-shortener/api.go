@@ -0,0 +1,242 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// APIPrefix is the path under which APIHandler serves the links
+const APIPrefix = "/api/links"
+
+// APIHandler returns an http.Handler serving a REST API to manage
+// the links of the store:
+//
+//	GET    /api/links         lists the links, of a namespace with
+//	                          the namespace parameter, only the broken
+//	                          ones with broken=true
+//	POST   /api/links         creates a link from {"path": ..., "url": ...}
+//	                          (the path is generated when it is omitted,
+//	                          in the namespace field when it is set)
+//	GET    /api/links/{path}  returns a link
+//	PUT    /api/links/{path}  changes the url and the options of a link
+//	                          from {"url": ...}
+//	DELETE /api/links/{path}  deletes a link
+//
+// Requests and responses are JSON encoded. The links may have the
+// optional fields expires_at, not_before, max_clicks, status and
+// cache_control. The health of their url is returned once it has been
+// checked, see Store.Check.
+//
+// When opts.AdminToken is set, every request needs the bearer token of
+// a User, who only sees the links of its namespaces and the shared ones.
+// The links are owned by the user who created them, and only the owner
+// or an admin can change them.
+func APIHandler(s *Store, opts APIOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, APIPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		user, err := authenticate(s, opts, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, APIPrefix)
+		if path == "" || path == "/" {
+			switch r.Method {
+			case http.MethodGet:
+				apiList(s, user, w, r)
+			case http.MethodPost:
+				apiCreate(s, opts, user, w, r)
+			default:
+				methodNotAllowed(w, http.MethodGet, http.MethodPost)
+			}
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if !user.canRead(path) {
+				writeError(w, ErrForbidden)
+				return
+			}
+			apiGet(s, w, path, http.StatusOK)
+		case http.MethodPut:
+			apiUpdate(s, user, w, r, path)
+		case http.MethodDelete:
+			apiDelete(s, user, w, path)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+		}
+	})
+}
+
+func apiList(s *Store, user *User, w http.ResponseWriter, r *http.Request) {
+	links, err := s.List()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ns := r.URL.Query().Get("namespace")
+	broken := r.URL.Query().Get("broken") == "true"
+	ret := []Link{}
+	for _, l := range links {
+		if (ns != "" && namespace(l.Path) != ns) || !user.canRead(l.Path) {
+			continue
+		}
+		if broken && (l.Health == nil || !l.Health.Broken) {
+			continue
+		}
+		ret = append(ret, l)
+	}
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func apiCreate(s *Store, opts APIOptions, user *User, w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Link
+		Namespace string `json:"namespace"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+		return
+	}
+	l := req.Link
+	if err := l.validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+		return
+	}
+	l.Served = 0
+	l.Health = nil
+	l.Owner = user.name()
+	if l.Path == "" {
+		if req.Namespace != "" {
+			l.Path = "/" + strings.Trim(req.Namespace, "/")
+			if err := validateAlias(l.Path, opts); err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+				return
+			}
+			if !user.canCreate(l.Path + "/") {
+				writeError(w, ErrForbidden)
+				return
+			}
+		}
+		link, created, err := s.Shorten(l, opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, link)
+		return
+	}
+
+	if !strings.HasPrefix(l.Path, "/") {
+		l.Path = "/" + l.Path
+	}
+	if err := validateAlias(l.Path, opts); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+		return
+	}
+	if !user.canCreate(l.Path) {
+		writeError(w, ErrForbidden)
+		return
+	}
+	l.Generated = false
+	if err := s.Create(l); err != nil {
+		writeError(w, err)
+		return
+	}
+	apiGet(s, w, l.Path, http.StatusCreated)
+}
+
+func apiGet(s *Store, w http.ResponseWriter, path string, status int) {
+	l, err := s.Get(path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, status, l)
+}
+
+func apiUpdate(s *Store, user *User, w http.ResponseWriter, r *http.Request, path string) {
+	var l Link
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+		return
+	}
+	if err := l.validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+		return
+	}
+	// The path of the request is authoritative
+	l.Path = path
+	if err := canChange(s, user, path); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.Update(l); err != nil {
+		writeError(w, err)
+		return
+	}
+	apiGet(s, w, path, http.StatusOK)
+}
+
+func apiDelete(s *Store, user *User, w http.ResponseWriter, path string) {
+	if err := canChange(s, user, path); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.Delete(path); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canChange checks that user can change the link of path
+func canChange(s *Store, user *User, path string) error {
+	l, err := s.Get(path)
+	if err != nil {
+		return err
+	}
+	if !user.canChange(l) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// writeError answers with the HTTP status matching a store error
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err {
+	case ErrNotFound:
+		status = http.StatusNotFound
+	case ErrExists:
+		status = http.StatusConflict
+	case ErrUnauthorized:
+		status = http.StatusUnauthorized
+	case ErrForbidden:
+		status = http.StatusForbidden
+	}
+	writeJSON(w, status, apiError{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+}
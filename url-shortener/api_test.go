@@ -0,0 +1,145 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testStore(t *testing.T) *Store {
+	dir, err := ioutil.TempDir("", "urlshort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := OpenStore(filepath.Join(dir, "links.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		os.RemoveAll(dir)
+	})
+	return s
+}
+
+func do(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestAPI(t *testing.T) {
+	s := testStore(t)
+	api := APIHandler(s, APIOptions{})
+	redirects := StoreHandler(s, http.NotFoundHandler(), RouteOptions{})
+
+	if w := do(api, "POST", "/api/links", `{"path":"/go","url":"https://golang.org"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected the link to be created. Got %d: %s", w.Code, w.Body)
+	}
+	if w := do(api, "POST", "/api/links", `{"path":"/go","url":"https://go.dev"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected a conflict when creating an existing link. Got %d", w.Code)
+	}
+	if w := do(redirects, "GET", "/go", ""); w.Header().Get("Location") != "https://golang.org" {
+		t.Errorf("Expected a redirection to https://golang.org. Got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := do(api, "PUT", "/api/links/go", `{"url":"https://go.dev"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected the link to be updated. Got %d: %s", w.Code, w.Body)
+	}
+	if w := do(redirects, "GET", "/go", ""); w.Header().Get("Location") != "https://go.dev" {
+		t.Errorf("Expected a redirection to https://go.dev. Got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w := do(api, "GET", "/api/links", "")
+	var links []Link
+	if err := json.NewDecoder(w.Body).Decode(&links); err != nil || len(links) != 1 {
+		t.Errorf("Expected a list of 1 link. Got %v (%v)", links, err)
+	}
+
+	if w := do(api, "DELETE", "/api/links/go", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected the link to be deleted. Got %d", w.Code)
+	}
+	if w := do(redirects, "GET", "/go", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the deleted link to fall back. Got %d", w.Code)
+	}
+	if w := do(api, "DELETE", "/api/links/go", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected deleting a missing link to fail. Got %d", w.Code)
+	}
+}
+
+func TestAPIInvalidURL(t *testing.T) {
+	s := testStore(t)
+	api := APIHandler(s, APIOptions{})
+	s.Create(Link{Path: "/go", URL: "https://golang.org"})
+
+	for _, url := range []string{"", "not a url", "/relative", "javascript:alert(1)", "ftp://example.com/file"} {
+		body := `{"path":"/x","url":"` + url + `"}`
+		if w := do(api, "POST", "/api/links", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected the creation to be rejected. Got %d", url, w.Code)
+		}
+		if w := do(api, "PUT", "/api/links/go", `{"url":"`+url+`"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected the update to be rejected. Got %d", url, w.Code)
+		}
+	}
+	if _, err := s.Get("/x"); err != ErrNotFound {
+		t.Errorf("Expected no link to be created. Got %v", err)
+	}
+	if l, _ := s.Get("/go"); l.URL != "https://golang.org" {
+		t.Errorf("Expected the link to be unchanged. Got %q", l.URL)
+	}
+}
+
+func TestAPIShorten(t *testing.T) {
+	s := testStore(t)
+	api := APIHandler(s, APIOptions{Dedupe: true})
+
+	create := func(body string) (Link, int) {
+		w := do(api, "POST", "/api/links", body)
+		var l Link
+		json.NewDecoder(w.Body).Decode(&l)
+		return l, w.Code
+	}
+
+	first, code := create(`{"url":"https://golang.org"}`)
+	if code != http.StatusCreated || first.Path != "/1" {
+		t.Fatalf("Expected the first generated path to be /1. Got %d: %+v", code, first)
+	}
+	again, code := create(`{"url":"https://golang.org"}`)
+	if code != http.StatusOK || again.Path != first.Path {
+		t.Errorf("Expected the url to be deduplicated. Got %d: %+v", code, again)
+	}
+	// An alias takes the next code of the sequence, which is then skipped
+	if _, code := create(`{"path":"2","url":"https://go.dev"}`); code != http.StatusCreated {
+		t.Fatalf("Expected the alias to be created. Got %d", code)
+	}
+	next, _ := create(`{"url":"https://go.dev/blog"}`)
+	if next.Path != "/3" {
+		t.Errorf("Expected the sequence to skip the alias. Got %+v", next)
+	}
+
+	for _, body := range []string{
+		`{"path":"/api/x","url":"https://go.dev"}`,
+		`{"path":"/Admin","url":"https://go.dev"}`,
+		`{"path":"/a b","url":"https://go.dev"}`,
+	} {
+		if _, code := create(body); code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected. Got %d", body, code)
+		}
+	}
+}
+
+func TestRandomCode(t *testing.T) {
+	s := testStore(t)
+	l, _, err := s.Shorten(Link{URL: "https://golang.org"}, APIOptions{RandomCodes: true, CodeLength: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Path) != 9 || !validAlias.MatchString(l.Path) {
+		t.Errorf("Expected a random path of 8 characters. Got %q", l.Path)
+	}
+}
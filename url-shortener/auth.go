@@ -0,0 +1,266 @@
+package urlshort
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var tokensBucket = []byte("tokens")
+
+// TokensPrefix is the path under which TokensHandler serves the tokens
+const TokensPrefix = "/api/tokens"
+
+var (
+	// ErrUnauthorized is returned for a missing or unknown token
+	ErrUnauthorized = errors.New("a valid token is required")
+	// ErrForbidden is returned when a user can't change a link
+	ErrForbidden = errors.New("not allowed")
+)
+
+// User is the owner of an API token. A user can create links in its
+// namespaces, the first segment of their path like /team-a/..., and
+// change the links it owns. An admin can do everything.
+type User struct {
+	Name       string    `json:"name"`
+	Namespaces []string  `json:"namespaces,omitempty"`
+	Admin      bool      `json:"admin,omitempty"`
+	Created    time.Time `json:"created"`
+}
+
+// adminUser is the user of the AdminToken of the APIOptions
+var adminUser = &User{Name: "admin", Admin: true}
+
+// namespace returns the namespace of a path, empty for a single segment
+func namespace(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0]
+}
+
+// canCreate tells whether u can create a link on path. The paths without
+// namespace are shared by every user.
+func (u *User) canCreate(path string) bool {
+	ns := namespace(path)
+	if u == nil || u.Admin || ns == "" {
+		return true
+	}
+	for _, n := range u.Namespaces {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// canRead tells whether u can see the link of path: the links of its
+// namespaces and the shared ones
+func (u *User) canRead(path string) bool {
+	return u.canCreate(path)
+}
+
+// canChange tells whether u can change or delete l. The links without an
+// owner can only be changed by an admin.
+func (u *User) canChange(l Link) bool {
+	return u == nil || u.Admin || (l.Owner != "" && l.Owner == u.Name)
+}
+
+func (u *User) name() string {
+	if u == nil {
+		return ""
+	}
+	return u.Name
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return []byte(hex.EncodeToString(sum[:]))
+}
+
+// CreateToken returns a new token for u. Only a hash of the token is kept,
+// so it can't be shown again.
+func (s *Store) CreateToken(u User) (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(secret)
+	u.Created = time.Now()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(tokensBucket)
+		if err != nil {
+			return err
+		}
+		exists := false
+		b.ForEach(func(k, v []byte) error {
+			var other User
+			if json.Unmarshal(v, &other) == nil && other.Name == u.Name {
+				exists = true
+			}
+			return nil
+		})
+		if exists {
+			return ErrExists
+		}
+		v, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		return b.Put(hashToken(token), v)
+	})
+	return token, err
+}
+
+// Users returns the owners of the tokens, sorted by name
+func (s *Store) Users() ([]User, error) {
+	users := []User{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tokensBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var u User
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			users = append(users, u)
+			return nil
+		})
+	})
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name < users[j].Name
+	})
+	return users, err
+}
+
+// RevokeToken deletes the token of the user name
+func (s *Store) RevokeToken(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tokensBucket)
+		if b == nil {
+			return ErrNotFound
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var u User
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			if u.Name == name {
+				return c.Delete()
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// Authenticate returns the user of a token
+func (s *Store) Authenticate(token string) (*User, error) {
+	var u *User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tokensBucket)
+		if b == nil {
+			return ErrUnauthorized
+		}
+		v := b.Get(hashToken(token))
+		if v == nil {
+			return ErrUnauthorized
+		}
+		u = &User{}
+		return json.Unmarshal(v, u)
+	})
+	return u, err
+}
+
+// authenticate returns the user of the bearer token of a request. It
+// returns nil when the API doesn't require tokens.
+func authenticate(s *Store, opts APIOptions, r *http.Request) (*User, error) {
+	if opts.AdminToken == "" {
+		return nil, nil
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, ErrUnauthorized
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if equal(token, opts.AdminToken) {
+		return adminUser, nil
+	}
+	return s.Authenticate(token)
+}
+
+type newToken struct {
+	User
+	Token string `json:"token"`
+}
+
+// TokensHandler returns an http.Handler serving the API managing the
+// tokens, for the admins only:
+//
+//	GET    /api/tokens         lists the users
+//	POST   /api/tokens         creates a token from {"name": ..., "namespaces": [...], "admin": false}
+//	DELETE /api/tokens/{name}  revokes the token of a user
+//
+// The token is only returned when it is created.
+func TokensHandler(s *Store, opts APIOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := authenticate(s, opts, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if u == nil || !u.Admin {
+			// Without an AdminToken, nobody manages the tokens
+			writeError(w, ErrForbidden)
+			return
+		}
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, TokensPrefix), "/")
+		switch {
+		case name == "" && r.Method == http.MethodGet:
+			users, err := s.Users()
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, users)
+		case name == "" && r.Method == http.MethodPost:
+			var nu User
+			if err := json.NewDecoder(r.Body).Decode(&nu); err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+				return
+			}
+			if nu.Name == "" || nu.Name == adminUser.Name {
+				writeJSON(w, http.StatusBadRequest, apiError{"invalid name"})
+				return
+			}
+			token, err := s.CreateToken(nu)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			nu.Created = time.Now()
+			writeJSON(w, http.StatusCreated, newToken{User: nu, Token: token})
+		case name == "":
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		case r.Method == http.MethodDelete:
+			if err := s.RevokeToken(name); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, http.MethodDelete)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	s := testStore(t)
+	opts := APIOptions{AdminToken: "root"}
+	api := APIHandler(s, opts)
+	tokens := TokensHandler(s, opts)
+
+	send := func(h http.Handler, method, target, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	newToken := func(body string) string {
+		w := send(tokens, "POST", "/api/tokens", "root", body)
+		var nt struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&nt); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("Expected a token to be created. Got %d (%v)", w.Code, err)
+		}
+		return nt.Token
+	}
+	alice := newToken(`{"name":"alice","namespaces":["team-a"]}`)
+	bob := newToken(`{"name":"bob","namespaces":["team-b"]}`)
+
+	if w := send(tokens, "GET", "/api/tokens", alice, ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected only the admins to manage the tokens. Got %d", w.Code)
+	}
+	if w := send(api, "POST", "/api/links", "", `{"path":"/x","url":"https://a.com"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a token to be required. Got %d", w.Code)
+	}
+	if w := send(api, "POST", "/api/links", "nope", `{"path":"/x","url":"https://a.com"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an unknown token to be rejected. Got %d", w.Code)
+	}
+
+	tests := []struct {
+		method, target, token, body string
+		status                      int
+	}{
+		{"POST", "/api/links", alice, `{"path":"/team-a/wiki","url":"https://a.com"}`, http.StatusCreated},
+		{"POST", "/api/links", bob, `{"path":"/team-a/docs","url":"https://b.com"}`, http.StatusForbidden},
+		{"POST", "/api/links", bob, `{"url":"https://b.com","namespace":"team-b"}`, http.StatusCreated},
+		{"POST", "/api/links", bob, `{"url":"https://b.com","namespace":"team-a"}`, http.StatusForbidden},
+		{"POST", "/api/links", bob, `{"path":"/shared","url":"https://b.com"}`, http.StatusCreated},
+		{"PUT", "/api/links/team-a/wiki", bob, `{"url":"https://b.com"}`, http.StatusForbidden},
+		{"PUT", "/api/links/shared", alice, `{"url":"https://a.com"}`, http.StatusForbidden},
+		{"PUT", "/api/links/team-a/wiki", alice, `{"url":"https://a.com/wiki"}`, http.StatusOK},
+		{"DELETE", "/api/links/shared", "root", "", http.StatusNoContent},
+	}
+	for _, test := range tests {
+		if w := send(api, test.method, test.target, test.token, test.body); w.Code != test.status {
+			t.Errorf("%s %s %s: expected status %d. Got %d: %s", test.method, test.target, test.body, test.status, w.Code, w.Body)
+		}
+	}
+
+	if w := send(api, "GET", "/api/links", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a token to be required to list the links. Got %d", w.Code)
+	}
+	w := send(api, "GET", "/api/links?namespace=team-a", bob, "")
+	var links []Link
+	json.NewDecoder(w.Body).Decode(&links)
+	if w.Code != http.StatusOK || len(links) != 0 {
+		t.Errorf("Expected bob not to see the links of team-a. Got %d: %+v", w.Code, links)
+	}
+	if w := send(api, "GET", "/api/links/team-a/wiki", bob, ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected bob not to get a link of team-a. Got %d", w.Code)
+	}
+	w = send(api, "GET", "/api/links", alice, "")
+	links = nil
+	json.NewDecoder(w.Body).Decode(&links)
+	for _, l := range links {
+		if namespace(l.Path) == "team-b" {
+			t.Errorf("Expected alice not to see the links of team-b. Got %+v", l)
+		}
+	}
+
+	w = send(api, "GET", "/api/links?namespace=team-b", "root", "")
+	links = nil
+	json.NewDecoder(w.Body).Decode(&links)
+	if len(links) != 1 || !strings.HasPrefix(links[0].Path, "/team-b/") || links[0].Owner != "bob" {
+		t.Errorf("Expected the generated link of bob in team-b. Got %+v", links)
+	}
+
+	if w := send(tokens, "DELETE", "/api/tokens/bob", "root", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected the token to be revoked. Got %d", w.Code)
+	}
+	if w := send(api, "DELETE", "/api/links"+links[0].Path, bob, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked token to be rejected. Got %d", w.Code)
+	}
+}
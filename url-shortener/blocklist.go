@@ -0,0 +1,169 @@
+package urlshort
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrBlocked is returned for an url rejected by a Blocklist
+var ErrBlocked = errors.New("url is blocked")
+
+// DefaultBlockedSchemes are the schemes blocked when a Blocklist doesn't
+// set any
+var DefaultBlockedSchemes = []string{"javascript", "data", "vbscript", "file"}
+
+// maxBody is the size of the request bodies read by the Blocklist
+const maxBody = 1 << 20
+
+// Blocklist rejects the links to some domains or schemes
+type Blocklist struct {
+	// Domains are blocked with their subdomains
+	Domains []string
+	// Schemes are blocked, DefaultBlockedSchemes if nil
+	Schemes []string
+	// SelfCheck rejects the links to the shortener itself, which would
+	// redirect in a loop: the host of the request and Hosts
+	SelfCheck bool
+	Hosts     []string
+}
+
+// Check returns an error wrapping ErrBlocked if the target of a link
+// created or followed by r is blocked. r may be nil outside of a request,
+// only Hosts are then checked by SelfCheck.
+func (b Blocklist) Check(target string, r *http.Request) error {
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBlocked, err)
+	}
+	schemes := b.Schemes
+	if schemes == nil {
+		schemes = DefaultBlockedSchemes
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return fmt.Errorf("%w: scheme %s", ErrBlocked, u.Scheme)
+		}
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	for _, domain := range b.Domains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return fmt.Errorf("%w: domain %s", ErrBlocked, domain)
+		}
+	}
+	if b.SelfCheck && host != "" {
+		hosts := b.Hosts
+		if r != nil {
+			hosts = append([]string{r.Host}, hosts...)
+		}
+		for _, self := range hosts {
+			if h, _, err := net.SplitHostPort(self); err == nil {
+				self = h
+			}
+			if strings.EqualFold(host, self) {
+				return fmt.Errorf("%w: %s is this shortener", ErrBlocked, host)
+			}
+		}
+	}
+	return nil
+}
+
+type blocklistKey struct{}
+
+// Handler returns a middleware enforcing the blocklist. The urls of the
+// links created or changed by next, in the url field of a JSON or form
+// body, are rejected with 400 Bad Request. The bodies of the bulk imports
+// aren't read: they check each of their links with the blocklist, see
+// ImportOptions.Check. The redirects
+// of next to a blocked url, whatever the source of the link, are replaced
+// with 403 Forbidden.
+func (b Blocklist) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := r
+		r = r.WithContext(context.WithValue(r.Context(), blocklistKey{}, func(target string) error {
+			return b.Check(target, req)
+		}))
+		// The bulk imports are bigger than maxBody, and check each link
+		bulk := strings.HasPrefix(r.URL.Path, BulkPrefix+"/")
+		if (r.Method == http.MethodPost || r.Method == http.MethodPut) && !bulk {
+			target, err := bodyURL(r)
+			if err == nil && target != "" {
+				err = b.Check(target, r)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(&blockWriter{ResponseWriter: w, r: r, list: b}, r)
+	})
+}
+
+// blocked returns the check of the Blocklist enforced on r, nil if there
+// is none
+func blocked(r *http.Request) func(target string) error {
+	check, _ := r.Context().Value(blocklistKey{}).(func(target string) error)
+	return check
+}
+
+// bodyURL returns the url field of the body of a request, leaving the body
+// readable by the next handler
+func bodyURL(r *http.Request) (string, error) {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "application/x-www-form-urlencoded" || ct == "multipart/form-data" {
+		// The parsed form stays available to the next handler
+		r.Body = http.MaxBytesReader(nil, r.Body, maxBody)
+		return r.FormValue("url"), nil
+	}
+	if r.Body == nil {
+		return "", nil
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBody))
+	if err != nil {
+		return "", err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var link struct {
+		URL string `json:"url"`
+	}
+	// Invalid JSON is reported by the next handler
+	json.Unmarshal(body, &link)
+	return link.URL, nil
+}
+
+// blockWriter replaces the redirects to a blocked url
+type blockWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	list    Blocklist
+	blocked bool
+}
+
+func (w *blockWriter) WriteHeader(status int) {
+	if isRedirect(status) {
+		if err := w.list.Check(w.Header().Get("Location"), w.r); err != nil {
+			w.blocked = true
+			w.Header().Del("Location")
+			http.Error(w.ResponseWriter, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *blockWriter) Write(b []byte) (int, error) {
+	if w.blocked {
+		// Drop the body of the redirect
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
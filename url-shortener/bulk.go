@@ -0,0 +1,462 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/go-yaml/yaml"
+)
+
+// BulkPrefix is the path under which BulkHandler serves the exports and
+// the imports
+const BulkPrefix = "/api/bulk"
+
+// Record is a link as it is exported and imported. It has the fields of
+// the entries of YAMLHandler, followed by metadata which is ignored by
+// the imports, except the owner.
+type Record struct {
+	Path     string `json:"path" yaml:"path"`
+	URL      string `json:"url" yaml:"url"`
+	Limits   `yaml:",inline"`
+	Redirect `yaml:",inline"`
+
+	Owner     string     `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Generated bool       `json:"generated,omitempty" yaml:"generated,omitempty"`
+	Created   *time.Time `json:"created,omitempty" yaml:"created,omitempty"`
+	Updated   *time.Time `json:"updated,omitempty" yaml:"updated,omitempty"`
+	Clicks    int        `json:"clicks,omitempty" yaml:"clicks,omitempty"`
+}
+
+// Change is a difference between the store and an import
+type Change struct {
+	// Op is "add", "change" or "remove"
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	// Old is the link in the store, nil for an add
+	Old *Link `json:"old,omitempty"`
+	// New is the link imported, nil for a remove
+	New *Link `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Op {
+	case "add":
+		return fmt.Sprintf("+ %s -> %s", c.Path, c.New.URL)
+	case "remove":
+		return fmt.Sprintf("- %s -> %s", c.Path, c.Old.URL)
+	}
+	if c.Old.URL != c.New.URL {
+		return fmt.Sprintf("~ %s -> %s (was %s)", c.Path, c.New.URL, c.Old.URL)
+	}
+	return fmt.Sprintf("~ %s -> %s (options)", c.Path, c.New.URL)
+}
+
+// ImportOptions configure Store.Import
+type ImportOptions struct {
+	// DryRun only returns the changes
+	DryRun bool
+	// Prune removes the links which aren't imported
+	Prune bool
+	// Owner owns the links imported without owner
+	Owner string
+	// API are the options used to validate the paths
+	API APIOptions
+	// Check rejects the urls which can't be imported, like the ones of a
+	// Blocklist, when it is set
+	Check func(target string) error
+}
+
+// Export returns every link with its number of clicks, sorted by path
+func (s *Store) Export() ([]Record, error) {
+	links, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.ClickCounts()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, len(links))
+	for i, l := range links {
+		created, updated := l.Created, l.Updated
+		records[i] = Record{
+			Path:      l.Path,
+			URL:       l.URL,
+			Limits:    l.Limits,
+			Redirect:  l.Redirect,
+			Owner:     l.Owner,
+			Generated: l.Generated,
+			Created:   &created,
+			Updated:   &updated,
+			Clicks:    counts[l.Path],
+		}
+	}
+	return records, nil
+}
+
+// Import applies records to the store in a single transaction: the new
+// paths are added and the links with other options are changed. The
+// changes are returned sorted by path.
+func (s *Store) Import(records []Record, opts ImportOptions) ([]Change, error) {
+	entries := make([]entry, len(records))
+	for i, rec := range records {
+		entries[i] = entry{Key: rec.Path, Value: rec.URL, Limits: rec.Limits, Redirect: rec.Redirect}
+	}
+	if err := validate(entries, nil); err != nil {
+		return nil, err
+	}
+	for i, rec := range records {
+		if err := validateAlias(rec.Path, opts.API); err != nil {
+			return nil, &ParseError{Entry: i, Err: err}
+		}
+		if opts.Check == nil {
+			continue
+		}
+		if err := opts.Check(rec.URL); err != nil {
+			return nil, &ParseError{Entry: i, Err: err}
+		}
+	}
+
+	var changes []Change
+	apply := func(tx *bolt.Tx) error {
+		b := tx.Bucket(linksBucket)
+		imported := make(map[string]bool)
+		for _, rec := range records {
+			imported[rec.Path] = true
+			l := Link{Path: rec.Path, URL: rec.URL, Limits: rec.Limits, Redirect: rec.Redirect, Owner: rec.Owner}
+			if l.Owner == "" {
+				l.Owner = opts.Owner
+			}
+			old, err := getLink(b, rec.Path)
+			switch {
+			case err == ErrNotFound:
+				changes = append(changes, Change{Op: "add", Path: l.Path, New: &l})
+				if !opts.DryRun {
+					if err := createLink(b, &l); err != nil {
+						return err
+					}
+				}
+				continue
+			case err != nil:
+				return err
+			case sameLink(old, l):
+				continue
+			}
+			l.Served, l.Generated, l.Created, l.Updated = old.Served, old.Generated, old.Created, time.Now()
+			if l.URL == old.URL {
+				l.Health = old.Health
+			}
+			changes = append(changes, Change{Op: "change", Path: l.Path, Old: &old, New: &l})
+			if !opts.DryRun {
+				if err := putLink(b, l); err != nil {
+					return err
+				}
+			}
+		}
+		if !opts.Prune {
+			return nil
+		}
+		var removed []Link
+		err := b.ForEach(func(k, v []byte) error {
+			var l Link
+			if err := json.Unmarshal(v, &l); err != nil {
+				return err
+			}
+			if !imported[l.Path] {
+				removed = append(removed, l)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i := range removed {
+			changes = append(changes, Change{Op: "remove", Path: removed[i].Path, Old: &removed[i]})
+			if !opts.DryRun {
+				if err := deleteLink(tx, removed[i].Path); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if opts.DryRun {
+		err = s.db.View(apply)
+	} else {
+		err = s.db.Update(apply)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// sameLink tells whether an import leaves a link unchanged
+func sameLink(a, b Link) bool {
+	return a.URL == b.URL && a.Redirect == b.Redirect && a.Owner == b.Owner &&
+		a.MaxClicks == b.MaxClicks && sameTime(a.ExpiresAt, b.ExpiresAt) && sameTime(a.NotBefore, b.NotBefore)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// WriteRecords writes records in YAML, JSON or CSV
+func WriteRecords(w io.Writer, format string, records []Record) error {
+	switch format {
+	case "yaml":
+		data, err := yaml.Marshal(records)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(csvColumns)
+		for _, rec := range records {
+			cw.Write(csvRecord(rec))
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// ReadRecords reads records in YAML, JSON or CSV. The YAML and JSON data
+// are lists of entries in the format of YAMLHandler and JSONHandler, with
+// the metadata fields of Record. The CSV data have a header with the names
+// of the fields, or only a path and an url on each line.
+func ReadRecords(data []byte, format string) ([]Record, error) {
+	var records []Record
+	switch format {
+	case "yaml":
+		if err := yaml.UnmarshalStrict(data, &records); err != nil {
+			return nil, yamlError(err)
+		}
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&records); err != nil {
+			return nil, jsonError(data, err)
+		}
+	case "csv":
+		return readCSVRecords(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return records, nil
+}
+
+var csvColumns = []string{"path", "url", "expires_at", "not_before", "max_clicks", "status",
+	"cache_control", "owner", "generated", "created", "updated", "clicks"}
+
+func csvRecord(rec Record) []string {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	itoa := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	return []string{rec.Path, rec.URL, formatTime(rec.ExpiresAt), formatTime(rec.NotBefore),
+		itoa(rec.MaxClicks), itoa(rec.Status), rec.CacheControl, rec.Owner,
+		strconv.FormatBool(rec.Generated), formatTime(rec.Created), formatTime(rec.Updated),
+		strconv.Itoa(rec.Clicks)}
+}
+
+func readCSVRecords(data []byte) ([]Record, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		perr := &ParseError{Entry: -1, Err: err}
+		var cerr *csv.ParseError
+		if errors.As(err, &cerr) {
+			perr.Line = cerr.Line
+		}
+		return nil, perr
+	}
+	columns := []string{"path", "url"}
+	if len(rows) > 0 && len(rows[0]) > 0 && rows[0][0] == "path" {
+		columns, rows = rows[0], rows[1:]
+	}
+	var records []Record
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return nil, &ParseError{Entry: i, Err: fmt.Errorf("expected %d fields, got %d", len(columns), len(row))}
+		}
+		var rec Record
+		for j, col := range columns {
+			if err := setCSVField(&rec, col, row[j]); err != nil {
+				return nil, &ParseError{Entry: i, Err: err}
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func setCSVField(rec *Record, column, value string) error {
+	parseTime := func(t **time.Time) error {
+		if value == "" {
+			return nil
+		}
+		v, err := time.Parse(time.RFC3339, value)
+		*t = &v
+		return err
+	}
+	atoi := func(n *int) error {
+		if value == "" {
+			return nil
+		}
+		var err error
+		*n, err = strconv.Atoi(value)
+		return err
+	}
+	switch column {
+	case "path":
+		rec.Path = value
+	case "url":
+		rec.URL = value
+	case "expires_at":
+		return parseTime(&rec.ExpiresAt)
+	case "not_before":
+		return parseTime(&rec.NotBefore)
+	case "max_clicks":
+		return atoi(&rec.MaxClicks)
+	case "status":
+		return atoi(&rec.Status)
+	case "cache_control":
+		rec.CacheControl = value
+	case "owner":
+		rec.Owner = value
+	case "generated":
+		rec.Generated = value == "true"
+	case "created":
+		return parseTime(&rec.Created)
+	case "updated":
+		return parseTime(&rec.Updated)
+	case "clicks":
+		return atoi(&rec.Clicks)
+	default:
+		return fmt.Errorf("unknown column %q", column)
+	}
+	return nil
+}
+
+// BulkHandler returns an http.Handler exporting and importing the links
+// of the store:
+//
+//	GET  /api/bulk/export?format=yaml                  exports every link
+//	POST /api/bulk/import?format=yaml&dry_run&prune    imports the body
+//
+// The format is yaml, json or csv, json by default. The import answers
+// with the changes made, or only computed with dry_run. The links are
+// checked with the Blocklist enforced on the request, if any. The links which
+// aren't imported are removed with prune. When opts.AdminToken is set,
+// both endpoints are reserved to the admins.
+func BulkHandler(s *Store, opts APIOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := authenticate(s, opts, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if user != nil && !user.Admin {
+			writeError(w, ErrForbidden)
+			return
+		}
+		q := r.URL.Query()
+		format := q.Get("format")
+		if format == "" {
+			format = "json"
+		}
+		switch strings.TrimPrefix(r.URL.Path, BulkPrefix) {
+		case "/export":
+			if r.Method != http.MethodGet {
+				methodNotAllowed(w, http.MethodGet)
+				return
+			}
+			records, err := s.Export()
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			var buf bytes.Buffer
+			if err := WriteRecords(&buf, format, records); err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+				return
+			}
+			w.Header().Set("Content-Type", contentTypes[format])
+			w.Write(buf.Bytes())
+		case "/import":
+			if r.Method != http.MethodPost {
+				methodNotAllowed(w, http.MethodPost)
+				return
+			}
+			data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 32<<20))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+				return
+			}
+			records, err := ReadRecords(data, format)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+				return
+			}
+			_, dryRun := q["dry_run"]
+			_, prune := q["prune"]
+			changes, err := s.Import(records, ImportOptions{DryRun: dryRun, Prune: prune, Owner: user.name(), API: opts, Check: blocked(r)})
+			var perr *ParseError
+			if errors.As(err, &perr) {
+				writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+				return
+			}
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			if changes == nil {
+				changes = []Change{}
+			}
+			writeJSON(w, http.StatusOK, changes)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+var contentTypes = map[string]string{
+	"yaml": "application/yaml",
+	"json": "application/json",
+	"csv":  "text/csv",
+}
@@ -0,0 +1,125 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	s := testStore(t)
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.Create(Link{Path: "/go", URL: "https://golang.org", Limits: Limits{ExpiresAt: &expires, MaxClicks: 5}})
+	s.Create(Link{Path: "/yaml", URL: "https://yaml.org", Redirect: Redirect{Status: 301}})
+	s.saveClicks([]Click{{Path: "/go", Time: time.Now()}, {Path: "/go", Time: time.Now()}})
+
+	for _, format := range []string{"yaml", "json", "csv"} {
+		records, err := s.Export()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := WriteRecords(&buf, format, records); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		read, err := ReadRecords(buf.Bytes(), format)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, buf.String())
+		}
+		if len(read) != 2 || read[0].Clicks != 2 || !read[0].ExpiresAt.Equal(expires) || read[1].Status != 301 {
+			t.Errorf("%s: expected the links to round trip. Got %+v", format, read)
+		}
+		changes, err := s.Import(read, ImportOptions{DryRun: true, Prune: true})
+		if err != nil || len(changes) != 0 {
+			t.Errorf("%s: expected no changes when importing an export. Got %v (%v)", format, changes, err)
+		}
+	}
+
+	records, err := ReadRecords([]byte("- path: /go\n  url: https://go.dev\n- path: /new\n  url: https://new.com\n"), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := s.Import(records, ImportOptions{DryRun: true, Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diff []string
+	for _, c := range changes {
+		diff = append(diff, c.String())
+	}
+	want := "~ /go -> https://go.dev (was https://golang.org)|+ /new -> https://new.com|- /yaml -> https://yaml.org"
+	if got := strings.Join(diff, "|"); got != want {
+		t.Errorf("Expected the diff %q. Got %q", want, got)
+	}
+	if l, _ := s.Get("/go"); l.URL != "https://golang.org" {
+		t.Errorf("Expected a dry run not to change the store. Got %+v", l)
+	}
+
+	h := BulkHandler(s, APIOptions{})
+	w := do(h, "POST", "/api/bulk/import?format=yaml&prune", "- path: /go\n  url: https://go.dev\n- path: /new\n  url: https://new.com\n")
+	if err := json.NewDecoder(w.Body).Decode(&changes); err != nil || w.Code != http.StatusOK || len(changes) != 3 {
+		t.Errorf("Expected 3 changes. Got %d: %v (%v)", w.Code, changes, err)
+	}
+	if links, _ := s.List(); len(links) != 2 || links[0].URL != "https://go.dev" || links[1].Path != "/new" {
+		t.Errorf("Expected the import to be applied. Got %+v", links)
+	}
+	if w := do(h, "POST", "/api/bulk/import?format=yaml", "- path: /a b\n  url: https://a.com\n"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid path to be rejected. Got %d", w.Code)
+	}
+	if w := do(h, "GET", "/api/bulk/export?format=csv", ""); w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "path,url,") {
+		t.Errorf("Expected a CSV export. Got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestImportBlocked(t *testing.T) {
+	s := testStore(t)
+	blocklist := Blocklist{Domains: []string{"evil.com"}}
+	h := blocklist.Handler(BulkHandler(s, APIOptions{}))
+
+	tests := []struct {
+		format string
+		body   string
+	}{
+		{"json", `[{"path":"/ok","url":"https://ok.com"},{"path":"/bad","url":"https://www.evil.com/x"}]`},
+		{"yaml", "- path: /ok\n  url: https://ok.com\n- path: /bad\n  url: https://evil.com\n"},
+		{"csv", "/ok,https://ok.com\n/bad,javascript:alert(1)\n"},
+		{"json", `[{"path":"/ok","url":"https://ok.com"},{"path":"/bad","url":"not a url"}]`},
+	}
+	for _, test := range tests {
+		for _, query := range []string{"", "&dry_run"} {
+			w := do(h, "POST", "/api/bulk/import?format="+test.format+query, test.body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "entry 1") {
+				t.Errorf("%s%s %s: expected the second entry to be rejected. Got %d: %s", test.format, query, test.body, w.Code, w.Body)
+			}
+		}
+	}
+	if links, _ := s.List(); len(links) != 0 {
+		t.Errorf("Expected nothing to be imported. Got %+v", links)
+	}
+
+	// Bigger than what the blocklist reads of the other bodies
+	var big bytes.Buffer
+	big.WriteString("[")
+	for i := 0; big.Len() < 2*maxBody; i++ {
+		if i > 0 {
+			big.WriteString(",")
+		}
+		fmt.Fprintf(&big, `{"path":"/link-%d","url":"https://ok.com/%d"}`, i, i)
+	}
+	big.WriteString("]")
+	if w := do(h, "POST", "/api/bulk/import?format=json&dry_run", big.String()); w.Code != http.StatusOK {
+		t.Errorf("Expected a large import to pass through the blocklist. Got %d: %.200s", w.Code, w.Body)
+	}
+
+	check := func(target string) error {
+		return blocklist.Check(target, nil)
+	}
+	if _, err := s.Import([]Record{{Path: "/bad", URL: "https://evil.com"}}, ImportOptions{Check: check}); !errors.Is(err, ErrBlocked) {
+		t.Errorf("Expected the import to be blocked. Got %v", err)
+	}
+}
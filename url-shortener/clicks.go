@@ -0,0 +1,291 @@
+package urlshort
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var clicksBucket = []byte("clicks")
+
+// Click is a redirect served by the url-shortener. The IP of the client is
+// only kept hashed.
+type Click struct {
+	Path      string    `json:"path"`
+	Time      time.Time `json:"time"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPHash    string    `json:"ip_hash"`
+}
+
+// maxBatch is the maximum number of clicks saved in a single transaction
+const maxBatch = 100
+
+// Recorder saves the clicks in a Store in the background, so that the
+// redirects don't wait for the database
+type Recorder struct {
+	store   *Store
+	salt    string
+	clicks  chan Click
+	dropped uint64
+	wg      sync.WaitGroup
+}
+
+// NewRecorder starts recording the clicks in s. Up to buffer clicks wait to
+// be saved, the next ones are dropped. The salt is added to the IPs before
+// hashing them so that they can't be guessed.
+func NewRecorder(s *Store, buffer int, salt string) *Recorder {
+	rec := &Recorder{
+		store:  s,
+		salt:   salt,
+		clicks: make(chan Click, buffer),
+	}
+	rec.wg.Add(1)
+	go rec.run()
+	return rec
+}
+
+// Record queues a click without blocking
+func (rec *Recorder) Record(c Click) {
+	select {
+	case rec.clicks <- c:
+	default:
+		atomic.AddUint64(&rec.dropped, 1)
+	}
+}
+
+// Dropped returns the number of clicks lost because the buffer was full
+func (rec *Recorder) Dropped() uint64 {
+	return atomic.LoadUint64(&rec.dropped)
+}
+
+// Close saves the queued clicks and stops the recorder. Record must not be
+// called afterwards.
+func (rec *Recorder) Close() {
+	close(rec.clicks)
+	rec.wg.Wait()
+}
+
+func (rec *Recorder) run() {
+	defer rec.wg.Done()
+	for c := range rec.clicks {
+		batch := []Click{c}
+		// Save what is already waiting in the same transaction
+	fill:
+		for len(batch) < maxBatch {
+			select {
+			case c, ok := <-rec.clicks:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, c)
+			default:
+				break fill
+			}
+		}
+		if err := rec.store.saveClicks(batch); err != nil {
+			log.Printf("failed to save %d clicks: %v", len(batch), err)
+		}
+	}
+}
+
+// Handler returns a middleware recording the redirects of the links of a
+// Store served by next, see StoreHandler. The other redirects of next
+// aren't clicks.
+func (rec *Recorder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, res := withResolved(r)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if res.link == "" || !isRedirect(sw.status) {
+			return
+		}
+		rec.Record(Click{
+			Path:      res.link,
+			Time:      time.Now(),
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+			IPHash:    rec.hashIP(clientIP(r)),
+		})
+	})
+}
+
+type resolvedKey struct{}
+
+// resolved is what the handlers of a request redirected to: the path of
+// a link of the Store, or of a route of the sources
+type resolved struct {
+	link  string
+	route string
+}
+
+// withResolved returns a request whose handlers report what they resolve,
+// reusing the report of an outer middleware
+func withResolved(r *http.Request) (*http.Request, *resolved) {
+	if res, ok := r.Context().Value(resolvedKey{}).(*resolved); ok {
+		return r, res
+	}
+	res := &resolved{}
+	return r.WithContext(context.WithValue(r.Context(), resolvedKey{}, res)), res
+}
+
+// resolveLink reports the redirect of r to the link of path. The probes
+// aren't reported.
+func resolveLink(r *http.Request, path string) {
+	if res, ok := r.Context().Value(resolvedKey{}).(*resolved); ok && !probing(r) {
+		res.link = path
+	}
+}
+
+// resolveRoute reports the redirect of r by the route of path, the
+// pattern for the pattern routes
+func resolveRoute(r *http.Request, path string) {
+	if res, ok := r.Context().Value(resolvedKey{}).(*resolved); ok && !probing(r) {
+		res.route = path
+	}
+}
+
+func (rec *Recorder) hashIP(ip string) string {
+	sum := sha256.Sum256([]byte(rec.salt + ip))
+	return hex.EncodeToString(sum[:16])
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// clientIP returns the IP of the client of a request, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusWriter remembers the status code and the size of the body written
+// by a handler
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// code returns the status of the response, 200 when the handler didn't
+// write anything
+func (w *statusWriter) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// saveClicks stores the clicks in a bucket per link
+func (s *Store) saveClicks(clicks []Click) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(clicksBucket)
+		if err != nil {
+			return err
+		}
+		for _, c := range clicks {
+			b, err := root.CreateBucketIfNotExists([]byte(c.Path))
+			if err != nil {
+				return err
+			}
+			id, _ := b.NextSequence()
+			v, err := json.Marshal(c)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(itob(id), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Clicks returns the clicks of a link since the given time, oldest first
+func (s *Store) Clicks(path string, since time.Time) ([]Click, error) {
+	var ret []Click
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := clicksOf(tx, path)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var c Click
+			if err := json.Unmarshal(v, &c); err != nil {
+				return err
+			}
+			if !c.Time.Before(since) {
+				ret = append(ret, c)
+			}
+			return nil
+		})
+	})
+	return ret, err
+}
+
+// ClickCounts returns the number of clicks recorded for each path
+func (s *Store) ClickCounts() (map[string]int, error) {
+	counts := make(map[string]int)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(clicksBucket)
+		if root == nil {
+			return nil
+		}
+		return root.ForEach(func(k, v []byte) error {
+			if b := root.Bucket(k); b != nil {
+				counts[string(k)] = b.Stats().KeyN
+			}
+			return nil
+		})
+	})
+	return counts, err
+}
+
+func clicksOf(tx *bolt.Tx, path string) *bolt.Bucket {
+	root := tx.Bucket(clicksBucket)
+	if root == nil {
+		return nil
+	}
+	return root.Bucket([]byte(path))
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,174 @@
+package urlshort
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+const base62 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// DefaultReserved are the first path segments that can't be used by a link
+// because the server uses them
+var DefaultReserved = []string{"api", "admin", "metrics", "static"}
+
+// ErrNoCode is returned when no free random code was found
+var ErrNoCode = errors.New("failed to generate a free short code")
+
+// maxRetries is the number of random codes tried before giving up
+const maxRetries = 10
+
+var validAlias = regexp.MustCompile("^/[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*$")
+
+// APIOptions configures how the API creates links
+type APIOptions struct {
+	// RandomCodes generates random codes of CodeLength characters for the
+	// links created without a path. Otherwise the codes encode a sequence.
+	RandomCodes bool
+	CodeLength  int
+	// Dedupe returns the existing link instead of creating a new one when
+	// the url was already shortened with a generated code
+	Dedupe bool
+	// Reserved first path segments, DefaultReserved if nil
+	Reserved []string
+	// AdminToken enables the tokens: the changes then need a bearer
+	// token, this one or one created with TokensHandler
+	AdminToken string
+}
+
+func (opts APIOptions) codeLength() int {
+	if opts.CodeLength <= 0 {
+		return 6
+	}
+	return opts.CodeLength
+}
+
+// validateAlias checks that a path chosen by a user is usable as a link
+func validateAlias(path string, opts APIOptions) error {
+	if !validAlias.MatchString(path) {
+		return fmt.Errorf("invalid path %q: only letters, digits, '-' and '_' are allowed", path)
+	}
+	reserved := opts.Reserved
+	if reserved == nil {
+		reserved = DefaultReserved
+	}
+	first := strings.SplitN(path[1:], "/", 2)[0]
+	for _, word := range reserved {
+		if strings.EqualFold(first, word) {
+			return fmt.Errorf("invalid path %q: %q is reserved", path, word)
+		}
+	}
+	return nil
+}
+
+// Shorten creates a link for l.URL on a generated path, under l.Path when
+// it is set to a namespace like /team-a. If opts.Dedupe is set and the url
+// already has a generated link without limits in the namespace, that link
+// is returned instead and created is false. A link with limits is always
+// created.
+func (s *Store) Shorten(l Link, opts APIOptions) (link Link, created bool, err error) {
+	prefix := strings.TrimSuffix(l.Path, "/")
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(linksBucket)
+		if opts.Dedupe && l.Limits == (Limits{}) {
+			existing, found, err := findGenerated(b, prefix, l.URL)
+			if err != nil || found {
+				link = existing
+				return err
+			}
+		}
+		path, err := newCode(b, prefix, opts)
+		if err != nil {
+			return err
+		}
+		l.Path = path
+		l.Generated = true
+		if err := createLink(b, &l); err != nil {
+			return err
+		}
+		link, created = l, true
+		return nil
+	})
+	return
+}
+
+// findGenerated looks for a generated link without limits pointing to url
+// under prefix
+func findGenerated(b *bolt.Bucket, prefix, url string) (Link, bool, error) {
+	var ret Link
+	var found bool
+	err := b.ForEach(func(k, v []byte) error {
+		if found {
+			return nil
+		}
+		var l Link
+		if err := json.Unmarshal(v, &l); err != nil {
+			return err
+		}
+		inPrefix := strings.HasPrefix(l.Path, prefix+"/") && !strings.Contains(l.Path[len(prefix)+1:], "/")
+		if l.Generated && inPrefix && l.URL == url && l.Limits == (Limits{}) {
+			ret, found = l, true
+		}
+		return nil
+	})
+	return ret, found, err
+}
+
+// newCode returns a path under prefix that isn't used by any link yet
+func newCode(b *bolt.Bucket, prefix string, opts APIOptions) (string, error) {
+	if !opts.RandomCodes {
+		for {
+			id, err := b.NextSequence()
+			if err != nil {
+				return "", err
+			}
+			// An alias may already use the code of the sequence
+			if path := prefix + "/" + encodeBase62(id); b.Get([]byte(path)) == nil {
+				return path, nil
+			}
+		}
+	}
+	for i := 0; i < maxRetries; i++ {
+		code, err := randomCode(opts.codeLength())
+		if err != nil {
+			return "", err
+		}
+		if path := prefix + "/" + code; b.Get([]byte(path)) == nil {
+			return path, nil
+		}
+	}
+	return "", ErrNoCode
+}
+
+func encodeBase62(n uint64) string {
+	if n == 0 {
+		return base62[:1]
+	}
+	var code []byte
+	for ; n > 0; n /= 62 {
+		code = append(code, base62[n%62])
+	}
+	for i, j := 0, len(code)-1; i < j; i, j = i+1, j-1 {
+		code[i], code[j] = code[j], code[i]
+	}
+	return string(code)
+}
+
+func randomCode(length int) (string, error) {
+	code := make([]byte, length)
+	max := big.NewInt(int64(len(base62)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = base62[n.Int64()]
+	}
+	return string(code), nil
+}
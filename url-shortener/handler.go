@@ -2,16 +2,16 @@ package urlshort
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/go-yaml/yaml"
 )
 
 type entry struct {
-	Key   string `json:"path" yaml:"path"`
-	Value string `json:"url" yaml:"url"`
+	Key      string `json:"path" yaml:"path" toml:"path"`
+	Value    string `json:"url" yaml:"url" toml:"url"`
+	Limits   `yaml:",inline"`
+	Redirect `yaml:",inline"`
 }
 
 // MapHandler will return an http.HandlerFunc (which also
@@ -20,21 +20,24 @@ type entry struct {
 // that each key in the map points to, in string format).
 // If the path is not provided in the map, then the fallback
 // http.Handler will be called instead.
+//
+// The paths can also be patterns with parameters, substituted in
+// the url:
+//
+//	/gh/{user}  -> https://github.com/{user}
+//	/docs/*     -> https://docs.example.com/*
+//
+// A path matching exactly always wins over the patterns. Among
+// the patterns, literal segments win over parameters, which win
+// over the wildcard, comparing the segments from the left.
 func MapHandler(pathsToUrls map[string]string, fallback http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		key := r.URL.Path
-		if address, present := pathsToUrls[key]; present {
-			http.Redirect(w, r, address, http.StatusFound)
-			return
-		}
-		fallback.ServeHTTP(w, r)
-	}
+	return RouteHandler(pathsToUrls, fallback, RouteOptions{})
 }
 
-func buildMap(entries []entry) map[string]string {
-	m := make(map[string]string)
+func buildMap(entries []entry) map[string]route {
+	m := make(map[string]route)
 	for _, ent := range entries {
-		m[ent.Key] = ent.Value
+		m[ent.Key] = route{target: ent.Value, Limits: ent.Limits, Redirect: ent.Redirect}
 	}
 	return m
 }
@@ -50,8 +53,16 @@ func buildMap(entries []entry) map[string]string {
 //     - path: /some-path
 //       url: https://www.some-url.com/demo
 //
-// The only errors that can be returned all related to having
-// invalid YAML data.
+// The entries may also limit when they redirect, with the optional
+// fields expires_at and not_before (RFC 3339 times) and max_clicks.
+// A link which expired answers 410 Gone. The optional status
+// (301, 302, 307 or 308) and cache_control fields set the status
+// code and the Cache-Control header of the redirect.
+//
+// The errors returned are *ParseError, carrying the line and the
+// index of the entry when they are known. They are caused by
+// invalid YAML data or invalid entries: paths must start with a
+// slash, be defined once, and urls must be absolute http(s) URLs.
 //
 // See MapHandler to create a similar http.HandlerFunc via
 // a mapping of paths to urls.
@@ -61,16 +72,18 @@ func YAMLHandler(yaml []byte, fallback http.Handler) (http.HandlerFunc, error) {
 		return nil, err
 	}
 	pathMap := buildMap(parsedYaml)
-	return MapHandler(pathMap, fallback), nil
+	return routeHandler(pathMap, fallback, RouteOptions{}, newHits()), nil
 }
 
-func parseYAML(yml []byte) (entries []entry, err error) {
-	err = yaml.UnmarshalStrict(yml, &entries)
-	if err != nil {
-		log.Fatalf("error: %v", err)
+func parseYAML(yml []byte) ([]entry, error) {
+	var entries []entry
+	if err := yaml.UnmarshalStrict(yml, &entries); err != nil {
+		return nil, yamlError(err)
 	}
-
-	return
+	if err := validate(entries, yamlLines(yml)); err != nil {
+		return nil, err
+	}
+	return entries, nil
 }
 
 // JSONHandler will parse the provided JSON and then return
@@ -81,26 +94,26 @@ func parseYAML(yml []byte) (entries []entry, err error) {
 //
 // JSON is expected to be in the format:
 //
-//     - path: /some-path
-//       url: https://www.some-url.com/demo
+//     [{"path": "/some-path", "url": "https://www.some-url.com/demo"}]
 //
-// The only errors that can be returned all related to having
-// invalid JSON data.
+// The entries may have the same optional fields as in YAMLHandler.
+// The errors returned are *ParseError, like for YAMLHandler.
 func JSONHandler(json []byte, fallback http.Handler) (http.HandlerFunc, error) {
 	parsedJSON, err := parseJSON(json)
 	if err != nil {
 		return nil, err
 	}
 	pathMap := buildMap(parsedJSON)
-	fmt.Println(pathMap)
-	return MapHandler(pathMap, fallback), nil
+	return routeHandler(pathMap, fallback, RouteOptions{}, newHits()), nil
 }
 
-func parseJSON(jsn []byte) (entries []entry, err error) {
-	err = json.Unmarshal(jsn, &entries)
-	if err != nil {
-		log.Fatalf("error: %v", err)
+func parseJSON(jsn []byte) ([]entry, error) {
+	var entries []entry
+	if err := json.Unmarshal(jsn, &entries); err != nil {
+		return nil, jsonError(jsn, err)
 	}
-
-	return
+	if err := validate(entries, jsonLines(jsn)); err != nil {
+		return nil, err
+	}
+	return entries, nil
 }
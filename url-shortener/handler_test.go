@@ -0,0 +1,50 @@
+package urlshort
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) ([]entry, error)
+		data  string
+		line  int
+		entry int
+	}{
+		{"yaml syntax", parseYAML, "- path: /a\n  url: [https://a.com\n", 2, -1},
+		{"yaml unknown field", parseYAML, "- path: /a\n  link: https://a.com\n", 2, -1},
+		{"yaml relative path", parseYAML, "- path: /a\n  url: https://a.com\n- path: b\n  url: https://b.com\n", 3, 1},
+		{"yaml duplicate", parseYAML, "- path: /a\n  url: https://a.com\n- path: /a\n  url: https://b.com\n", 3, 1},
+		{"yaml javascript", parseYAML, "- path: /a\n  url: javascript:alert(1)\n", 1, 0},
+		{"json syntax", parseJSON, "[\n  {\"path\": \"/a\",}\n]", 2, -1},
+		{"json type", parseJSON, "[\n  {\"path\": 1}\n]", 2, -1},
+		{"json relative url", parseJSON, "[\n  {\"path\": \"/a\", \"url\": \"https://a.com\"},\n  {\"path\": \"/b\", \"url\": \"b.com\"}\n]", 3, 1},
+	}
+	for _, test := range tests {
+		_, err := test.parse([]byte(test.data))
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: expected a *ParseError. Got %v", test.name, err)
+			continue
+		}
+		if perr.Line != test.line || perr.Entry != test.entry {
+			t.Errorf("%s: expected an error on line %d, entry %d. Got %q", test.name, test.line, test.entry, perr)
+		}
+	}
+}
+
+func TestYAMLHandler(t *testing.T) {
+	h, err := YAMLHandler([]byte("- path: /a\n  url: https://a.com\n"), http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := do(h, "GET", "/a", "").Header().Get("Location"); got != "https://a.com" {
+		t.Errorf("Expected a redirection to https://a.com. Got %q", got)
+	}
+	if _, err := YAMLHandler([]byte("- path: a\n"), http.NotFoundHandler()); err == nil {
+		t.Error("Expected an error for an invalid entry")
+	}
+}
@@ -0,0 +1,219 @@
+package urlshort
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Health is the result of the last checks of the url of a link
+type Health struct {
+	// Status is the status code of the last check, 0 when the url could
+	// not be reached
+	Status int `json:"status"`
+	// FinalURL is the url reached after following the redirects
+	FinalURL string `json:"final_url,omitempty"`
+	// Error is the error of the last check, if any
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+	// Failures is the number of consecutive failed checks
+	Failures int `json:"failures,omitempty"`
+	// Broken is true once the url failed CheckOptions.Failures times in
+	// a row
+	Broken bool `json:"broken,omitempty"`
+}
+
+// CheckOptions configure the checks of the urls of the links
+type CheckOptions struct {
+	// Concurrency is the number of urls checked at the same time, 4 if 0
+	Concurrency int
+	// Timeout is the maximum duration of a check, 10s if 0
+	Timeout time.Duration
+	// Failures is the number of consecutive failed checks after which a
+	// link is flagged as broken, 3 if 0
+	Failures int
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+func (opts CheckOptions) withDefaults() CheckOptions {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Failures <= 0 {
+		opts.Failures = 3
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return opts
+}
+
+// checkURL sends a HEAD request to url, then a GET request if the server
+// doesn't answer HEAD requests. An url is healthy when it answers with a
+// status below 400 after following the redirects.
+func checkURL(ctx context.Context, client *http.Client, url string) (h Health, ok bool) {
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			h.Error = err.Error()
+			return h, false
+		}
+		req.Header.Set("User-Agent", "url-shortener link checker")
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			h.Status, h.FinalURL, h.Error = 0, "", err.Error()
+			continue
+		}
+		resp.Body.Close()
+		h.Status, h.FinalURL, h.Error = resp.StatusCode, resp.Request.URL.String(), ""
+		if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+			break
+		}
+	}
+	return h, h.Error == "" && h.Status < 400
+}
+
+// Check checks the urls of all the links, at most opts.Concurrency at a
+// time, and records their health in the store. It returns the links
+// checked, with their new health.
+func (s *Store) Check(opts CheckOptions) ([]Link, error) {
+	return s.check(context.Background(), opts)
+}
+
+// check works like Check, stopping when ctx is canceled. The checks in
+// progress are then dropped, and ctx.Err() is returned.
+func (s *Store) check(ctx context.Context, opts CheckOptions) ([]Link, error) {
+	opts = opts.withDefaults()
+	links, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	checked := make([]Link, len(links))
+	errs := make([]error, len(links))
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i, l := range links {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, l Link) {
+			defer func() { <-sem; wg.Done() }()
+			urlCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+			h, ok := checkURL(urlCtx, opts.Client, l.URL)
+			cancel()
+			if ctx.Err() != nil {
+				// Not a failure of the url
+				return
+			}
+			h.Checked = time.Now()
+			checked[i], errs[i] = s.recordHealth(l, h, ok, opts.Failures)
+		}(i, l)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ret := checked[:0]
+	for i, l := range checked {
+		switch errs[i] {
+		case nil:
+			ret = append(ret, l)
+		case ErrNotFound:
+			// Deleted or changed during the check
+		default:
+			return nil, errs[i]
+		}
+	}
+	return ret, nil
+}
+
+// recordHealth saves the result of a check of l, counting the consecutive
+// failures. The result is dropped with ErrNotFound when the link was
+// deleted or its url changed during the check.
+func (s *Store) recordHealth(l Link, h Health, ok bool, failures int) (Link, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(linksBucket)
+		current, err := getLink(b, l.Path)
+		if err != nil {
+			return err
+		}
+		if current.URL != l.URL {
+			return ErrNotFound
+		}
+		if !ok {
+			h.Failures = 1
+			if current.Health != nil {
+				h.Failures = current.Health.Failures + 1
+			}
+		}
+		h.Broken = h.Failures >= failures
+		current.Health = &h
+		l = current
+		return putLink(b, current)
+	})
+	return l, err
+}
+
+// Checker checks the urls of the links in the background, right away then
+// every interval. It returns a function stopping it, which cancels the
+// check in progress and waits for it to end. It is disabled when interval
+// isn't positive.
+func (s *Store) Checker(interval time.Duration, opts CheckOptions) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer close(exited)
+		defer ticker.Stop()
+		for {
+			s.logCheck(ctx, opts)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-exited
+	}
+}
+
+// logCheck checks the links and logs the number of broken ones
+func (s *Store) logCheck(ctx context.Context, opts CheckOptions) {
+	links, err := s.check(ctx, opts)
+	if err == context.Canceled {
+		return
+	}
+	if err != nil {
+		log.Printf("check: %v", err)
+		return
+	}
+	broken := 0
+	for _, l := range links {
+		if l.Health.Broken {
+			broken++
+		}
+	}
+	if broken > 0 {
+		log.Printf("%d broken links", broken)
+	}
+}
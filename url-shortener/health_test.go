@@ -0,0 +1,164 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	var inflight, maxInflight int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInflight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInflight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	s := testStore(t)
+	for _, l := range []Link{
+		{Path: "/a", URL: srv.URL + "/ok"},
+		{Path: "/b", URL: srv.URL + "/ok"},
+		{Path: "/c", URL: srv.URL + "/ok"},
+		{Path: "/moved", URL: srv.URL + "/moved"},
+		{Path: "/get-only", URL: srv.URL + "/get-only"},
+		{Path: "/missing", URL: srv.URL + "/missing"},
+		{Path: "/down", URL: down.URL},
+	} {
+		if err := s.Create(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := CheckOptions{Concurrency: 2, Failures: 2, Timeout: time.Second}
+	links, err := s.Check(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 7 {
+		t.Fatalf("Expected 7 links checked. Got %d", len(links))
+	}
+	if max := atomic.LoadInt32(&maxInflight); max > 2 {
+		t.Errorf("Expected at most 2 checks at a time. Got %d", max)
+	}
+
+	tests := []struct {
+		path     string
+		status   int
+		finalURL string
+		failures int
+	}{
+		{"/a", http.StatusOK, srv.URL + "/ok", 0},
+		{"/moved", http.StatusOK, srv.URL + "/ok", 0},
+		{"/get-only", http.StatusOK, srv.URL + "/get-only", 0},
+		{"/missing", http.StatusNotFound, srv.URL + "/missing", 1},
+		{"/down", 0, "", 1},
+	}
+	for _, test := range tests {
+		l, err := s.Get(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := l.Health
+		if h == nil {
+			t.Errorf("%s: expected the health to be recorded", test.path)
+			continue
+		}
+		if h.Status != test.status || h.FinalURL != test.finalURL || h.Failures != test.failures || h.Broken {
+			t.Errorf("%s: expected status %d, final url %q and %d failures. Got %+v", test.path, test.status, test.finalURL, test.failures, *h)
+		}
+		if h.Checked.IsZero() {
+			t.Errorf("%s: expected the time of the check", test.path)
+		}
+	}
+
+	// The second failure in a row flags the links as broken
+	if _, err := s.Check(opts); err != nil {
+		t.Fatal(err)
+	}
+	for path, broken := range map[string]bool{"/a": false, "/missing": true, "/down": true} {
+		l, _ := s.Get(path)
+		if l.Health.Broken != broken {
+			t.Errorf("%s: expected broken to be %v. Got %+v", path, broken, *l.Health)
+		}
+	}
+
+	api := APIHandler(s, APIOptions{})
+	var ret []Link
+	json.Unmarshal(do(api, "GET", "/api/links?broken=true", "").Body.Bytes(), &ret)
+	if len(ret) != 2 || ret[0].Path != "/down" || ret[1].Path != "/missing" {
+		t.Errorf("Expected the broken links /down and /missing. Got %+v", ret)
+	}
+
+	// Changing the url forgets the health of the old one
+	if err := s.Update(Link{Path: "/missing", URL: srv.URL + "/ok"}); err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := s.Get("/missing"); l.Health != nil {
+		t.Errorf("Expected the health to be reset with the url. Got %+v", *l.Health)
+	}
+	if err := s.Update(Link{Path: "/down", URL: down.URL, Redirect: Redirect{Status: http.StatusMovedPermanently}}); err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := s.Get("/down"); l.Health == nil || !l.Health.Broken {
+		t.Errorf("Expected the health to be kept with the same url. Got %+v", l.Health)
+	}
+}
+
+func TestChecker(t *testing.T) {
+	started := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	s := testStore(t)
+	if err := s.Create(Link{Path: "/slow", URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := s.Checker(time.Hour, CheckOptions{Timeout: time.Minute})
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a check to start right away")
+	}
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected stop to cancel the check in progress")
+	}
+	if l, _ := s.Get("/slow"); l.Health != nil {
+		t.Errorf("Expected a canceled check not to be recorded. Got %+v", *l.Health)
+	}
+}
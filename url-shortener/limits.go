@@ -0,0 +1,190 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Limits restrict when a link redirects. The zero value never expires.
+type Limits struct {
+	// ExpiresAt is the time the link stops redirecting
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty" toml:"expires_at,omitempty"`
+	// MaxClicks is the number of redirects served before the link stops
+	// redirecting, unlimited when 0
+	MaxClicks int `json:"max_clicks,omitempty" yaml:"max_clicks,omitempty" toml:"max_clicks,omitempty"`
+	// NotBefore is the time the link starts redirecting
+	NotBefore *time.Time `json:"not_before,omitempty" yaml:"not_before,omitempty" toml:"not_before,omitempty"`
+}
+
+// status tells whether a link redirects at the given time, after clicks
+// redirects
+type status int
+
+const (
+	active status = iota
+	pending
+	gone
+)
+
+func (l Limits) status(now time.Time, clicks int) status {
+	switch {
+	case l.NotBefore != nil && now.Before(*l.NotBefore):
+		return pending
+	case l.ExpiresAt != nil && !now.Before(*l.ExpiresAt):
+		return gone
+	case l.MaxClicks > 0 && clicks >= l.MaxClicks:
+		return gone
+	}
+	return active
+}
+
+func (l Limits) validate() error {
+	switch {
+	case l.MaxClicks < 0:
+		return fmt.Errorf("max_clicks must not be negative")
+	case l.NotBefore != nil && l.ExpiresAt != nil && !l.NotBefore.Before(*l.ExpiresAt):
+		return fmt.Errorf("not_before must be before expires_at")
+	}
+	return nil
+}
+
+// inactive answers a request for a link which doesn't redirect. A link
+// which isn't active yet is unknown, so the fallback is called. An expired
+// link is Gone, unless the options fall through.
+func inactive(w http.ResponseWriter, r *http.Request, st status, fallback http.Handler, opts RouteOptions) {
+	if st == pending || opts.FallThrough {
+		fallback.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, "This link has expired", http.StatusGone)
+}
+
+// hits counts the redirects served by the routes with a MaxClicks. They
+// are only kept in memory, so the count starts over when the server
+// restarts.
+type hits struct {
+	mu sync.Mutex
+	n  map[string]int
+}
+
+func newHits() *hits {
+	return &hits{n: make(map[string]int)}
+}
+
+// take counts a redirect of path, returning the status of the link before
+// it
+func (h *hits) take(path string, l Limits, now time.Time) status {
+	if l.MaxClicks == 0 {
+		return l.status(now, 0)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := l.status(now, h.n[path])
+	if st == active {
+		h.n[path]++
+	}
+	return st
+}
+
+// status returns the status of the route of path without counting a
+// redirect
+func (h *hits) status(path string, l Limits, now time.Time) status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return l.status(now, h.n[path])
+}
+
+// hit counts a redirect of a link of the store when count is true,
+// returning the link and its status before the redirect. The links without
+// a MaxClicks are only read.
+func (s *Store) hit(path string, now time.Time, count bool) (Link, status, error) {
+	l, err := s.Get(path)
+	if err != nil || l.MaxClicks == 0 || !count {
+		return l, l.status(now, 0), err
+	}
+	var st status
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(linksBucket)
+		var err error
+		if l, err = getLink(b, path); err != nil {
+			return err
+		}
+		if st = l.status(now, l.Served); st != active {
+			return nil
+		}
+		l.Served++
+		return putLink(b, l)
+	})
+	return l, st, err
+}
+
+// Purge deletes the links which expired or reached their MaxClicks, and
+// returns their number. Their clicks are kept for the analytics.
+func (s *Store) Purge(now time.Time) (int, error) {
+	var expired []string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(linksBucket).ForEach(func(k, v []byte) error {
+			var l Link
+			if err := json.Unmarshal(v, &l); err != nil {
+				return err
+			}
+			if l.status(now, l.Served) == gone {
+				expired = append(expired, l.Path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		b := tx.Bucket(linksBucket)
+		for _, path := range expired {
+			if err := b.Delete([]byte(path)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+// Janitor purges the expired links every interval in the background. It
+// returns a function stopping it. It is disabled when interval isn't
+// positive.
+func (s *Store) Janitor(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := s.Purge(time.Now())
+				if err != nil {
+					log.Printf("purge: %v", err)
+				} else if n > 0 {
+					log.Printf("purged %d expired links", n)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
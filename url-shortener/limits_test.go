@@ -0,0 +1,96 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	yml := "- path: /expired\n  url: https://a.com\n  expires_at: " + past + "\n" +
+		"- path: /later\n  url: https://b.com\n  not_before: " + future + "\n" +
+		"- path: /twice\n  url: https://c.com\n  max_clicks: 2\n" +
+		"- path: /now\n  url: https://d.com\n  not_before: " + past + "\n  expires_at: " + future + "\n"
+	h, err := YAMLHandler([]byte(yml), http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/expired", http.StatusGone},
+		{"/later", http.StatusNotFound},
+		{"/twice", http.StatusFound},
+		{"/twice", http.StatusFound},
+		{"/twice", http.StatusGone},
+		{"/now", http.StatusFound},
+	}
+	for _, test := range tests {
+		if got := do(h, "GET", test.path, "").Code; got != test.status {
+			t.Errorf("%s: expected status %d. Got %d", test.path, test.status, got)
+		}
+	}
+
+	h = routeHandler(buildMap([]entry{{Key: "/expired", Value: "https://a.com", Limits: Limits{MaxClicks: 1}}}),
+		http.NotFoundHandler(), RouteOptions{FallThrough: true}, newHits())
+	do(h, "GET", "/expired", "")
+	if got := do(h, "GET", "/expired", "").Code; got != http.StatusNotFound {
+		t.Errorf("Expected an expired link to fall through. Got status %d", got)
+	}
+
+	if _, err := parseJSON([]byte(`[{"path":"/a","url":"https://a.com","max_clicks":-1}]`)); err == nil {
+		t.Error("Expected an error for a negative max_clicks")
+	}
+	if _, err := parseJSON([]byte(`[{"path":"/a","url":"https://a.com","not_before":"` + future + `","expires_at":"` + past + `"}]`)); err == nil {
+		t.Error("Expected an error for a link expiring before it starts")
+	}
+}
+
+func TestStoreLimits(t *testing.T) {
+	s := testStore(t)
+	h := StoreHandler(s, http.NotFoundHandler(), RouteOptions{})
+	expired := time.Now().Add(-time.Minute)
+	s.Create(Link{Path: "/once", URL: "https://a.com", Limits: Limits{MaxClicks: 1}})
+	s.Create(Link{Path: "/old", URL: "https://b.com", Limits: Limits{ExpiresAt: &expired}})
+	s.Create(Link{Path: "/keep", URL: "https://c.com"})
+
+	if got := do(h, "GET", "/once", "").Code; got != http.StatusFound {
+		t.Errorf("Expected the first click to redirect. Got status %d", got)
+	}
+	if got := do(h, "GET", "/once", "").Code; got != http.StatusGone {
+		t.Errorf("Expected the second click to be Gone. Got status %d", got)
+	}
+	if got := do(h, "GET", "/old", "").Code; got != http.StatusGone {
+		t.Errorf("Expected an expired link to be Gone. Got status %d", got)
+	}
+
+	if err := s.saveClicks([]Click{{Path: "/old", Time: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Purge(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 links purged. Got %d", n)
+	}
+	if links, _ := s.List(); len(links) != 1 || links[0].Path != "/keep" {
+		t.Errorf("Expected only /keep to remain. Got %v", links)
+	}
+	if clicks, _ := s.Clicks("/old", time.Time{}); len(clicks) != 1 {
+		t.Errorf("Expected the clicks of a purged link to be kept. Got %v", clicks)
+	}
+}
+
+func TestBackgroundDisabled(t *testing.T) {
+	s := testStore(t)
+	for _, interval := range []time.Duration{0, -time.Second} {
+		stop := s.Janitor(interval)
+		stop()
+		stop = s.Checker(interval, CheckOptions{})
+		stop()
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	urlshort "gophercises/url-shortener"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runBulk runs the export and import subcommands:
+//
+//	main export [-db links.db] [-format json] [file]
+//	main import [-db links.db] [-format json] [-dry-run] [-prune] [-block-domains ...] file
+//
+// The format defaults to the extension of the file. It returns false when
+// the arguments aren't a subcommand.
+func runBulk(args []string) bool {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return false
+	}
+	fs := flag.NewFlagSet(args[0], flag.ExitOnError)
+	dbFilename := fs.String("db", "links.db", "the BoltDB file storing the links")
+	format := fs.String("format", "", "yaml, json or csv, the extension of the file by default")
+	dryRun := fs.Bool("dry-run", false, "only show the changes of the import")
+	prune := fs.Bool("prune", false, "remove the links which aren't in the imported file")
+	blockedDomains := fs.String("block-domains", "", "comma separated domains which can't be linked to")
+	blockedSchemes := fs.String("block-schemes", strings.Join(urlshort.DefaultBlockedSchemes, ","), "comma separated url schemes which can't be linked to")
+	fs.Parse(args[1:])
+	file := fs.Arg(0)
+	if *format == "" {
+		switch filepath.Ext(file) {
+		case ".yml", ".yaml":
+			*format = "yaml"
+		case ".csv":
+			*format = "csv"
+		default:
+			*format = "json"
+		}
+	}
+
+	store, err := urlshort.OpenStore(*dbFilename)
+	if err != nil {
+		log.Fatalf("Failed to open the store %s: %v", *dbFilename, err)
+	}
+	defer store.Close()
+
+	if args[0] == "export" {
+		records, err := store.Export()
+		if err != nil {
+			log.Fatalf("Failed to export the links: %v", err)
+		}
+		out := os.Stdout
+		if file != "" {
+			if out, err = os.Create(file); err != nil {
+				log.Fatalf("Failed to create %s: %v", file, err)
+			}
+			defer out.Close()
+		}
+		if err := urlshort.WriteRecords(out, *format, records); err != nil {
+			log.Fatalf("Failed to export the links: %v", err)
+		}
+		return true
+	}
+
+	if file == "" {
+		log.Fatalf("Usage: %s import [-dry-run] [-prune] file", os.Args[0])
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", file, err)
+	}
+	records, err := urlshort.ReadRecords(data, *format)
+	if err != nil {
+		log.Fatalf("%s: %v", file, err)
+	}
+	blocklist := urlshort.Blocklist{Domains: split(*blockedDomains), Schemes: split(*blockedSchemes)}
+	check := func(target string) error {
+		return blocklist.Check(target, nil)
+	}
+	changes, err := store.Import(records, urlshort.ImportOptions{DryRun: *dryRun, Prune: *prune, Check: check})
+	if err != nil {
+		log.Fatalf("Failed to import %s: %v", file, err)
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if *dryRun {
+		fmt.Printf("%d changes (dry run, nothing was applied)\n", len(changes))
+	} else {
+		fmt.Printf("%d changes applied\n", len(changes))
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	urlshort "gophercises/url-shortener"
+	"log"
+	"time"
+)
+
+// runCheck runs the check subcommand, checking the urls of the links of
+// the store once and printing the failing ones:
+//
+//	main check [-db links.db] [-concurrency 4] [-timeout 10s] [-failures 3]
+//
+// It returns false when the arguments aren't the subcommand.
+func runCheck(args []string) bool {
+	if len(args) == 0 || args[0] != "check" {
+		return false
+	}
+	fs := flag.NewFlagSet(args[0], flag.ExitOnError)
+	dbFilename := fs.String("db", "links.db", "the BoltDB file storing the links")
+	concurrency := fs.Int("concurrency", 4, "number of urls checked at the same time")
+	timeout := fs.Duration("timeout", 10*time.Second, "maximum duration of a check")
+	failures := fs.Int("failures", 3, "consecutive failed checks after which a link is broken")
+	fs.Parse(args[1:])
+
+	store, err := urlshort.OpenStore(*dbFilename)
+	if err != nil {
+		log.Fatalf("Failed to open the store %s: %v", *dbFilename, err)
+	}
+	defer store.Close()
+
+	links, err := store.Check(urlshort.CheckOptions{Concurrency: *concurrency, Timeout: *timeout, Failures: *failures})
+	if err != nil {
+		log.Fatalf("Failed to check the links: %v", err)
+	}
+	failed := 0
+	for _, l := range links {
+		h := l.Health
+		if h.Failures == 0 {
+			continue
+		}
+		failed++
+		state := "failing"
+		if h.Broken {
+			state = "broken"
+		}
+		result := h.Error
+		if result == "" {
+			result = fmt.Sprintf("%d %s", h.Status, h.FinalURL)
+		}
+		fmt.Printf("%s %s -> %s: %s (%d failures)\n", state, l.Path, l.URL, result, h.Failures)
+	}
+	fmt.Printf("%d links checked, %d failing\n", len(links), failed)
+	return true
+}
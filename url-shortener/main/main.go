@@ -1,57 +1,224 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	urlshort "gophercises/url-shortener"
-	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
-	var yamlFilename = flag.String("-yml", "default.yml", "a YAML file in the format :\n- path: /some-path\n  url: url: https://www.some-url.com/demo")
-	var jsonFilename = flag.String("-json", "default.json", "a YAML file in the format :\n- path: /some-path\n  url: url: https://www.some-url.com/demo")
+	if runBulk(os.Args[1:]) || runCheck(os.Args[1:]) {
+		return
+	}
+
+	var yamlFilename = flag.String("yml", "default.yml", "a YAML file in the format :\n- path: /some-path\n  url: url: https://www.some-url.com/demo")
+	var jsonFilename = flag.String("json", "default.json", "a JSON file in the format :\n[{\"path\": \"/some-path\", \"url\": \"https://www.some-url.com/demo\"}]")
+	var tomlFilename = flag.String("toml", "", "an optional TOML file of [[links]] tables with a path and an url")
+	var csvFilename = flag.String("csv", "", "an optional CSV file with a path and an url on each line")
+	var envPrefix = flag.String("env", "URLSHORT_", "prefix of the environment variables defining redirects")
+	var passQuery = flag.Bool("pass-query", false, "append the query string of the requests to the redirect urls")
+	var previews = flag.Bool("preview", true, "show the url of a link on its path followed by a +")
+	var fallThrough = flag.Bool("fallthrough", false, "serve the expired links like unknown paths instead of answering 410 Gone")
+	var janitor = flag.Duration("janitor", time.Hour, "interval between the purges of the expired links from the store (disabled when 0)")
+	var checkInterval = flag.Duration("check", 0, "interval between the checks of the urls of the links, like 6h (disabled when 0)")
+	var checkConcurrency = flag.Int("check-concurrency", 4, "number of urls checked at the same time")
+	var checkFailures = flag.Int("check-failures", 3, "consecutive failed checks after which a link is flagged as broken")
+	var adminUser = flag.String("admin-user", "", "user of the admin UI")
+	var adminPassword = flag.String("admin-password", "", "password of the admin UI")
+	var adminToken = flag.String("admin-token", "", "token giving access to the admin UI, as a bearer token or a password")
+	var tmplDir = flag.String("tmpl", "../tmpl/", "directory of the templates of the admin UI")
+	var redirectRate = flag.Float64("rate", 10, "requests per second allowed to each client (unlimited when 0)")
+	var redirectBurst = flag.Int("burst", 50, "burst of requests allowed to each client")
+	var createRate = flag.Float64("create-rate", 0.2, "links per second each client can create (unlimited when 0)")
+	var createBurst = flag.Int("create-burst", 10, "burst of links each client can create")
+	var blockedDomains = flag.String("block-domains", "", "comma separated domains which can't be linked to")
+	var blockedSchemes = flag.String("block-schemes", strings.Join(urlshort.DefaultBlockedSchemes, ","), "comma separated url schemes which can't be linked to")
+	var selfCheck = flag.Bool("self-check", true, "reject the links to the shortener itself")
+	var apiToken = flag.String("api-token", "", "admin token of the API, requiring tokens to change the links when set")
+	var baseURL = flag.String("base-url", "", "url of the shortener encoded in the QR codes, like https://sho.rt (the host of the request by default)")
+	var qrSize = flag.Int("qr-size", 256, "default size of the QR codes in pixels")
+	var qrLevel = flag.String("qr-level", "M", "default error correction level of the QR codes: L, M, Q or H")
+	var addr = flag.String("addr", ":8080", "address the server listens on")
+	var readTimeout = flag.Duration("read-timeout", 5*time.Second, "maximum duration to read a request")
+	var writeTimeout = flag.Duration("write-timeout", 10*time.Second, "maximum duration to write a response")
+	var idleTimeout = flag.Duration("idle-timeout", time.Minute, "maximum duration a keep-alive connection stays idle")
+	var shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "maximum duration to finish the requests on SIGTERM")
+	var accessLog = flag.Bool("access-log", true, "write a JSON access log line to stdout for every request")
+	var dbFilename = flag.String("db", "links.db", "the BoltDB file storing the links managed through the API")
+	var randomCodes = flag.Bool("random", false, "generate random short codes instead of sequential ones")
+	var codeLength = flag.Int("code-length", 6, "length of the random short codes")
+	var dedupe = flag.Bool("dedupe", false, "reuse the generated short code of an url already shortened")
+	var salt = flag.String("salt", "", "secret added to the client IPs before hashing them in the click analytics")
 	flag.Parse()
-	mux := defaultMux()
 
-	// Build the MapHandler using the mux as the fallback
+	store, err := urlshort.OpenStore(*dbFilename)
+	if err != nil {
+		log.Fatalf("Failed to open the store %s: %v", *dbFilename, err)
+	}
+	defer store.Close()
+
+	stopJanitor := store.Janitor(*janitor)
+	defer stopJanitor()
+
+	stopChecker := store.Checker(*checkInterval, urlshort.CheckOptions{Concurrency: *checkConcurrency, Failures: *checkFailures})
+	defer stopChecker()
+
+	recorder := urlshort.NewRecorder(store, 1024, *salt)
+	defer recorder.Close()
+
+	apiOpts := urlshort.APIOptions{
+		RandomCodes: *randomCodes,
+		CodeLength:  *codeLength,
+		Dedupe:      *dedupe,
+		AdminToken:  *apiToken,
+	}
+	metrics := urlshort.NewMetrics()
+	mux := defaultMux(store, apiOpts, metrics)
+
+	// The admin UI is only served when credentials are configured
+	if *adminToken != "" || *adminUser != "" {
+		admin, err := urlshort.AdminHandler(store, urlshort.AdminOptions{
+			TemplateDir: *tmplDir,
+			User:        *adminUser,
+			Password:    *adminPassword,
+			Token:       *adminToken,
+			API:         apiOpts,
+		})
+		if err != nil {
+			log.Fatalf("Failed to start the admin UI: %v", err)
+		}
+		mux.Handle(urlshort.AdminPrefix, admin)
+		mux.Handle(urlshort.AdminPrefix+"/", admin)
+	}
+
+	// Build the StoreHandler using the mux as the fallback
+	routeOpts := urlshort.RouteOptions{PassQuery: *passQuery, FallThrough: *fallThrough, Preview: *previews}
+	storeHandler := urlshort.StoreHandler(store, mux, routeOpts)
+
+	// Build the ReloadHandler serving the redirects of every source,
+	// using the StoreHandler as the fallback. The first sources take
+	// precedence over the next ones.
 	pathsToUrls := map[string]string{
 		"/urlshort-godoc": "https://godoc.org/github.com/gophercises/urlshort",
 		"/yaml-godoc":     "https://godoc.org/gopkg.in/yaml.v2",
 	}
-	mapHandler := urlshort.MapHandler(pathsToUrls, mux)
-
-	// Build the YAMLHandler
-	yaml, err := ioutil.ReadFile(*yamlFilename)
-	if err != nil {
-		log.Printf("Failed to open file %s ", *yamlFilename)
+	sources := []urlshort.Source{
+		urlshort.FileSource(*jsonFilename),
+		urlshort.FileSource(*yamlFilename),
 	}
-	yamlHandler, err := urlshort.YAMLHandler(yaml, mapHandler)
+	for _, name := range []string{*tomlFilename, *csvFilename} {
+		if name != "" {
+			sources = append(sources, urlshort.FileSource(name))
+		}
+	}
+	sources = append(sources, urlshort.EnvSource(*envPrefix), urlshort.MapSource(pathsToUrls))
+	fileHandler, err := urlshort.NewReloadHandler(storeHandler, sources...)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Failed to load the redirects: %v", err)
 	}
+	fileHandler.SetOptions(routeOpts)
+	stopWatch := fileHandler.Watch(2 * time.Second)
+	defer stopWatch()
 
-	// Build the JSONHandler
-	json, err := ioutil.ReadFile(*jsonFilename)
-	if err != nil {
-		log.Printf("Failed to open file %s ", *jsonFilename)
+	// Serve the QR codes of the links, and protect the whole chain: the
+	// blocklist checks the links created and the redirects, the limiters
+	// slow down the abusive clients
+	blocklist := urlshort.Blocklist{
+		Domains:   split(*blockedDomains),
+		Schemes:   split(*blockedSchemes),
+		SelfCheck: *selfCheck,
 	}
-	jsonHandler, err := urlshort.JSONHandler(json, yamlHandler)
-	if err != nil {
-		panic(err)
+	var handler http.Handler = fileHandler
+	handler = urlshort.QRHandler(handler, urlshort.QROptions{BaseURL: *baseURL, Size: *qrSize, Level: *qrLevel})
+	handler = onPaths(urlshort.NewRateLimiter(*createRate, *createBurst).Handler(handler, http.MethodPost), handler,
+		urlshort.APIPrefix, urlshort.APIPrefix+"/")
+	handler = urlshort.NewRateLimiter(*redirectRate, *redirectBurst).Handler(handler)
+	handler = blocklist.Handler(handler)
+	handler = metrics.Handler(recorder.Handler(handler))
+	if *accessLog {
+		handler = urlshort.AccessLog(os.Stdout, handler)
+	}
+
+	srv := &http.Server{
+		Addr:         *addr,
+		Handler:      handler,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
 	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+		<-sig
+		log.Printf("Shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Failed to shut down gracefully: %v", err)
+		}
+	}()
 
-	fmt.Println("Starting the server on :8080")
-	http.ListenAndServe(":8080", jsonHandler)
+	fmt.Println("Starting the server on", *addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalf("Failed to serve: %v", err)
+	}
+	// Wait for the requests to finish before closing the store
+	<-done
 }
 
-func defaultMux() *http.ServeMux {
+func defaultMux(store *urlshort.Store, opts urlshort.APIOptions, metrics *urlshort.Metrics) *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", hello)
+	api := urlshort.APIHandler(store, opts)
+	mux.Handle(urlshort.APIPrefix, api)
+	mux.Handle(urlshort.APIPrefix+"/", api)
+	bulk := urlshort.BulkHandler(store, opts)
+	mux.Handle(urlshort.BulkPrefix+"/", bulk)
+	tokens := urlshort.TokensHandler(store, opts)
+	mux.Handle(urlshort.TokensPrefix, tokens)
+	mux.Handle(urlshort.TokensPrefix+"/", tokens)
+	stats := urlshort.StatsHandler(store, opts)
+	mux.Handle(urlshort.StatsPrefix, stats)
+	mux.Handle(urlshort.StatsPrefix+"/", stats)
+	mux.Handle(urlshort.MetricsPath, metrics)
+	mux.Handle("/", metrics.Fallback(http.HandlerFunc(hello)))
 	return mux
 }
 
+// onPaths returns a handler passing the requests for one of the paths to
+// h, and the other ones to next
+func onPaths(h, next http.Handler, paths ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range paths {
+			if r.URL.Path == path {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// split returns the comma separated values of a flag
+func split(values string) []string {
+	ret := []string{}
+	for _, v := range strings.Split(values, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
 func hello(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "Hello, world!")
 }
@@ -0,0 +1,208 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsPath is the path of the metrics in main
+const MetricsPath = "/metrics"
+
+// latencyBuckets are the upper bounds of the latency histogram, in seconds
+var latencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// Metrics counts the requests served by a handler, and serves them in the
+// Prometheus text format
+type Metrics struct {
+	mu        sync.Mutex
+	codes     map[int]uint64
+	redirects map[string]uint64
+	fallbacks uint64
+	// buckets are the number of requests of each latency bucket, the
+	// last one counting the slower requests
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewMetrics returns empty Metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		codes:     make(map[int]uint64),
+		redirects: make(map[string]uint64),
+		buckets:   make([]uint64, len(latencyBuckets)+1),
+	}
+}
+
+// otherRedirects is the label of the redirects of neither a link nor a
+// route, like the redirects of the admin UI
+const otherRedirects = "other"
+
+// Handler returns a middleware counting the requests served by next by
+// status code, the redirects by link or route, and their latency. The
+// paths of the links and routes are known, so the number of labels stays
+// bounded: the patterns count the redirects of all their paths, and the
+// other redirects are counted together.
+func (m *Metrics) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, res := withResolved(r)
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		label := res.link
+		if label == "" {
+			label = res.route
+		}
+		if label == "" {
+			label = otherRedirects
+		}
+		m.observe(label, sw.code(), time.Since(start))
+	})
+}
+
+// Fallback returns a middleware counting the requests reaching next, the
+// last fallback of the chain. The probes of QRHandler aren't counted.
+func (m *Metrics) Fallback(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !probing(r) {
+			m.mu.Lock()
+			m.fallbacks++
+			m.mu.Unlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *Metrics) observe(path string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[status]++
+	if isRedirect(status) {
+		m.redirects[path]++
+	}
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, seconds)
+	m.buckets[i]++
+	m.sum += seconds
+	m.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ew := &errWriter{w: w}
+
+	fmt.Fprintln(ew, "# HELP urlshort_requests_total Requests served, by status code.")
+	fmt.Fprintln(ew, "# TYPE urlshort_requests_total counter")
+	codes := make([]int, 0, len(m.codes))
+	for code := range m.codes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(ew, "urlshort_requests_total{code=\"%d\"} %d\n", code, m.codes[code])
+	}
+
+	fmt.Fprintln(ew, "# HELP urlshort_redirects_total Redirects served, by link or route.")
+	fmt.Fprintln(ew, "# TYPE urlshort_redirects_total counter")
+	paths := make([]string, 0, len(m.redirects))
+	for path := range m.redirects {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(ew, "urlshort_redirects_total{path=\"%s\"} %d\n", labelValue(path), m.redirects[path])
+	}
+
+	fmt.Fprintln(ew, "# HELP urlshort_fallbacks_total Requests matching no link.")
+	fmt.Fprintln(ew, "# TYPE urlshort_fallbacks_total counter")
+	fmt.Fprintf(ew, "urlshort_fallbacks_total %d\n", m.fallbacks)
+
+	fmt.Fprintln(ew, "# HELP urlshort_request_duration_seconds Latency of the requests.")
+	fmt.Fprintln(ew, "# TYPE urlshort_request_duration_seconds histogram")
+	var cumulative uint64
+	for i, le := range latencyBuckets {
+		cumulative += m.buckets[i]
+		fmt.Fprintf(ew, "urlshort_request_duration_seconds_bucket{le=\"%s\"} %d\n",
+			strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(ew, "urlshort_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.count)
+	fmt.Fprintf(ew, "urlshort_request_duration_seconds_sum %s\n", strconv.FormatFloat(m.sum, 'g', -1, 64))
+	fmt.Fprintf(ew, "urlshort_request_duration_seconds_count %d\n", m.count)
+	return ew.n, ew.err
+}
+
+// errWriter keeps the first error of a series of writes
+type errWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (ew *errWriter) Write(b []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	n, err := ew.w.Write(b)
+	ew.n += int64(n)
+	ew.err = err
+	return n, err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelValue(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// accessLog is a line of the access logs
+type accessLog struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Bytes     int       `json:"bytes"`
+	Duration  float64   `json:"duration_ms"`
+	IP        string    `json:"ip"`
+	Location  string    `json:"location,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// AccessLog returns a middleware writing a JSON line to out for every
+// request served by next
+func AccessLog(out io.Writer, next http.Handler) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		line, _ := json.Marshal(accessLog{
+			Time:      start.UTC(),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    sw.code(),
+			Bytes:     sw.bytes,
+			Duration:  float64(time.Since(start).Microseconds()) / 1000,
+			IP:        clientIP(r),
+			Location:  sw.Header().Get("Location"),
+			UserAgent: r.UserAgent(),
+		})
+		mu.Lock()
+		out.Write(append(line, '\n'))
+		mu.Unlock()
+	})
+}
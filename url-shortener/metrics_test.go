@@ -0,0 +1,71 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	fallback := m.Fallback(http.NotFoundHandler())
+	admin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			http.Redirect(w, r, "/admin/", http.StatusSeeOther)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
+	routes := map[string]string{"/go": "https://golang.org", `/a"b`: "https://a.com", "/gh/{user}": "https://github.com/{user}"}
+	h := m.Handler(MapHandler(routes, admin))
+	do(h, "GET", "/go", "")
+	do(h, "GET", "/go", "")
+	do(h, "GET", `/a"b`, "")
+	do(h, "GET", "/gh/gopher", "")
+	do(h, "GET", "/gh/gophers", "")
+	do(h, "POST", "/admin/links", "")
+	do(h, "POST", "/admin/links/go", "")
+	do(h, "GET", "/missing", "")
+
+	w := do(m, "GET", "/metrics", "")
+	for _, want := range []string{
+		`urlshort_requests_total{code="302"} 5`,
+		`urlshort_requests_total{code="303"} 2`,
+		`urlshort_requests_total{code="404"} 1`,
+		`urlshort_redirects_total{path="/go"} 2`,
+		`urlshort_redirects_total{path="/a\"b"} 1`,
+		`urlshort_redirects_total{path="/gh/{user}"} 2`,
+		`urlshort_redirects_total{path="other"} 2`,
+		`urlshort_fallbacks_total 1`,
+		`urlshort_request_duration_seconds_bucket{le="+Inf"} 8`,
+		`urlshort_request_duration_seconds_count 8`,
+	} {
+		if !strings.Contains(w.Body.String(), want+"\n") {
+			t.Errorf("Expected the metrics to contain %s. Got:\n%s", want, w.Body)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	h := AccessLog(&out, MapHandler(map[string]string{"/go": "https://golang.org"}, http.NotFoundHandler()))
+	do(h, "GET", "/go", "")
+	do(h, "GET", "/missing", "")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines. Got %q", out.String())
+	}
+	var l accessLog
+	if err := json.Unmarshal([]byte(lines[0]), &l); err != nil {
+		t.Fatal(err)
+	}
+	if l.Method != "GET" || l.Path != "/go" || l.Status != http.StatusFound || l.Location != "https://golang.org" || l.Bytes == 0 {
+		t.Errorf("Expected the redirect to be logged. Got %+v", l)
+	}
+	if json.Unmarshal([]byte(lines[1]), &l); l.Status != http.StatusNotFound {
+		t.Errorf("Expected a 404 to be logged. Got %+v", l)
+	}
+}
@@ -0,0 +1,174 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseError describes an invalid redirect file or entry
+type ParseError struct {
+	// File is the name of the file, empty when parsing data in memory
+	File string
+	// Line is the line of the error, 0 when it isn't known
+	Line int
+	// Entry is the index of the invalid entry, -1 when the data couldn't
+	// be decoded at all
+	Entry int
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		b.WriteString(":")
+	}
+	if e.Line > 0 {
+		b.WriteString(strconv.Itoa(e.Line))
+		b.WriteString(":")
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	if e.Entry >= 0 {
+		fmt.Fprintf(&b, "entry %d: ", e.Entry)
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// inFile sets the file of a ParseError
+func inFile(err error, name string) error {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		perr.File = name
+	}
+	return err
+}
+
+// validate checks every entry, lines being the line where each entry starts
+// (when known)
+func validate(entries []entry, lines []int) error {
+	first := make(map[string]int)
+	for i, e := range entries {
+		var err error
+		switch {
+		case !strings.HasPrefix(e.Key, "/"):
+			err = fmt.Errorf("path %q must start with /", e.Key)
+		case first[e.Key] > 0:
+			err = fmt.Errorf("duplicate path %q, already defined by entry %d", e.Key, first[e.Key]-1)
+		default:
+			err = validateURL(e.Value)
+			if err == nil && isPattern(e.Key) {
+				_, err = compilePattern(e.Key, e.Value)
+			}
+			if err == nil {
+				err = e.Limits.validate()
+			}
+			if err == nil {
+				err = e.Redirect.validate()
+			}
+		}
+		if err != nil {
+			perr := &ParseError{Entry: i, Err: err}
+			if i < len(lines) {
+				perr.Line = lines[i]
+			}
+			return perr
+		}
+		first[e.Key] = i + 1
+	}
+	return nil
+}
+
+// validateURL checks that a target is an absolute http(s) URL
+func validateURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %v", target, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q must be an absolute http or https URL", target)
+	}
+	return nil
+}
+
+var yamlErrLine = regexp.MustCompile(`line (\d+)`)
+
+// yamlError wraps an error of the YAML decoder, which reports its line in
+// the message
+func yamlError(err error) error {
+	perr := &ParseError{Entry: -1, Err: err}
+	if m := yamlErrLine.FindStringSubmatch(err.Error()); m != nil {
+		perr.Line, _ = strconv.Atoi(m[1])
+	}
+	return perr
+}
+
+// yamlLines returns the line of every item of a top level YAML sequence,
+// a dash alone or followed by a space. The document markers like --- aren't
+// items.
+func yamlLines(yml []byte) []int {
+	var lines []int
+	for i, line := range bytes.Split(yml, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if bytes.Equal(line, []byte("-")) || bytes.HasPrefix(line, []byte("- ")) || bytes.HasPrefix(line, []byte("-\t")) {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// jsonError wraps an error of the JSON decoder with the line of its offset
+func jsonError(jsn []byte, err error) error {
+	perr := &ParseError{Entry: -1, Err: err}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		perr.Line = lineAt(jsn, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		perr.Line = lineAt(jsn, typeErr.Offset)
+	}
+	return perr
+}
+
+// jsonLines returns the line of every element of a top level JSON array
+func jsonLines(jsn []byte) []int {
+	var lines []int
+	dec := json.NewDecoder(bytes.NewReader(jsn))
+	if _, err := dec.Token(); err != nil {
+		return nil
+	}
+	for dec.More() {
+		offset := dec.InputOffset()
+		// Skip the separators up to the element itself
+		for offset < int64(len(jsn)) && bytes.IndexByte([]byte(", \t\r\n"), jsn[offset]) >= 0 {
+			offset++
+		}
+		lines = append(lines, lineAt(jsn, offset))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			break
+		}
+	}
+	return lines
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
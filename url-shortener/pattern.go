@@ -0,0 +1,216 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RouteOptions change how the redirects are built
+type RouteOptions struct {
+	// PassQuery appends the query string of the request to the url
+	PassQuery bool
+	// FallThrough calls the fallback for the links which expired or
+	// reached their MaxClicks, instead of answering 410 Gone
+	FallThrough bool
+	// Preview serves a page showing the url of a link on its path
+	// followed by a +, like /go+
+	Preview bool
+}
+
+// route is the target of a path, its limits and how it redirects
+type route struct {
+	target string
+	Limits
+	Redirect
+}
+
+// A pattern is a path with parameters:
+//
+//	/gh/{user}      matches a single segment, used as {user} in the url
+//	/docs/*         matches the rest of the path, used as * in the url
+//
+// The wildcard may only be the last segment.
+type pattern struct {
+	path     string
+	segments []string
+	route
+}
+
+var paramName = regexp.MustCompile(`^\{[A-Za-z_][A-Za-z0-9_]*\}$`)
+
+// isPattern tells whether a path has parameters
+func isPattern(path string) bool {
+	return strings.ContainsAny(path, "{}*")
+}
+
+// compilePattern checks a pattern and the parameters used by its url
+func compilePattern(path, target string) (pattern, error) {
+	p := pattern{path: path, segments: strings.Split(path, "/")[1:], route: route{target: target}}
+	params := make(map[string]bool)
+	for i, seg := range p.segments {
+		switch {
+		case seg == "*":
+			if i != len(p.segments)-1 {
+				return p, fmt.Errorf("pattern %q: * must be the last segment", path)
+			}
+			params["*"] = true
+		case paramName.MatchString(seg):
+			if params[seg] {
+				return p, fmt.Errorf("pattern %q: duplicate parameter %s", path, seg)
+			}
+			params[seg] = true
+		case strings.ContainsAny(seg, "{}*"):
+			return p, fmt.Errorf("pattern %q: invalid segment %q", path, seg)
+		}
+	}
+	for _, used := range placeholders(target) {
+		if !params[used] {
+			return p, fmt.Errorf("url %q uses %s, which isn't in the pattern %q", target, used, path)
+		}
+	}
+	return p, nil
+}
+
+var placeholder = regexp.MustCompile(`\{[A-Za-z_][A-Za-z0-9_]*\}|\*`)
+
+func placeholders(target string) []string {
+	return placeholder.FindAllString(target, -1)
+}
+
+// match returns the url of a request path, false if it doesn't match
+func (p pattern) match(path string) (string, bool) {
+	segments := strings.Split(path, "/")[1:]
+	values := make(map[string]string)
+	for i, seg := range p.segments {
+		if seg == "*" {
+			values["*"] = strings.Join(segments[i:], "/")
+			break
+		}
+		if i >= len(segments) {
+			return "", false
+		}
+		switch {
+		case paramName.MatchString(seg):
+			if segments[i] == "" {
+				return "", false
+			}
+			values[seg] = segments[i]
+		case seg != segments[i]:
+			return "", false
+		}
+		if i == len(p.segments)-1 && len(segments) != len(p.segments) {
+			return "", false
+		}
+	}
+	return placeholder.ReplaceAllStringFunc(p.target, func(name string) string {
+		return values[name]
+	}), true
+}
+
+// kind ranks the segments of patterns: literals are more specific than
+// parameters, which are more specific than the wildcard
+func kind(seg string) int {
+	switch {
+	case seg == "*":
+		return 2
+	case paramName.MatchString(seg):
+		return 1
+	}
+	return 0
+}
+
+// moreSpecific orders the patterns so that the first match is the most
+// specific one, comparing the segments from the left. Patterns as
+// specific as each other are sorted by path.
+func moreSpecific(a, b pattern) bool {
+	for i := 0; i < len(a.segments) && i < len(b.segments); i++ {
+		if ka, kb := kind(a.segments[i]), kind(b.segments[i]); ka != kb {
+			return ka < kb
+		}
+	}
+	if len(a.segments) != len(b.segments) {
+		return len(a.segments) > len(b.segments)
+	}
+	return a.path < b.path
+}
+
+// RouteHandler works like MapHandler, with options changing how the
+// redirects are built.
+func RouteHandler(pathsToUrls map[string]string, fallback http.Handler, opts RouteOptions) http.HandlerFunc {
+	routes := make(map[string]route, len(pathsToUrls))
+	for path, target := range pathsToUrls {
+		routes[path] = route{target: target}
+	}
+	return routeHandler(routes, fallback, opts, newHits())
+}
+
+// routeHandler redirects the paths of the routes, counting the redirects
+// of the routes with a MaxClicks in hits
+func routeHandler(routes map[string]route, fallback http.Handler, opts RouteOptions, hits *hits) http.HandlerFunc {
+	exact := make(map[string]route)
+	var patterns []pattern
+	for path, rt := range routes {
+		if !isPattern(path) {
+			exact[path] = rt
+			continue
+		}
+		p, err := compilePattern(path, rt.target)
+		if err != nil {
+			// The entries are validated when parsed, but a map given
+			// directly may still be invalid: match the path as is
+			exact[path] = rt
+			continue
+		}
+		p.route = rt
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return moreSpecific(patterns[i], patterns[j])
+	})
+
+	// lookup returns the route matching a path, the path of the route
+	// and the url of the redirect
+	lookup := func(path, escaped string) (route, string, string, bool) {
+		if rt, present := exact[path]; present {
+			return rt, path, rt.target, true
+		}
+		for _, p := range patterns {
+			if address, present := p.match(escaped); present {
+				return p.route, p.path, address, true
+			}
+		}
+		return route{}, "", "", false
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// A preview wins over the patterns, which could match the +
+		if path, ok := previewPath(r, opts); ok && exact[r.URL.Path].target == "" {
+			escaped := strings.TrimSuffix(r.URL.EscapedPath(), "+")
+			if rt, key, address, present := lookup(path, escaped); present {
+				if st := hits.status(key, rt.Limits, time.Now()); st != pending {
+					preview(w, r, path, address, st, opts)
+					return
+				}
+			}
+		}
+		rt, key, address, present := lookup(r.URL.Path, r.URL.EscapedPath())
+		if !present {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		take := hits.take
+		if probing(r) {
+			take = hits.status
+		}
+		if st := take(key, rt.Limits, time.Now()); st != active {
+			inactive(w, r, st, fallback, opts)
+			return
+		}
+		resolveRoute(r, key)
+		redirect(w, r, address, rt.Redirect, rt.Limits, opts)
+	}
+}
@@ -0,0 +1,66 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPatterns(t *testing.T) {
+	h := MapHandler(map[string]string{
+		"/gh/{user}":        "https://github.com/{user}",
+		"/gh/{user}/{repo}": "https://github.com/{user}/{repo}",
+		"/gh/gophercises":   "https://gophercises.com",
+		"/gh/*":             "https://github.com/search?q=*",
+		"/jira/{id}":        "https://jira.example.com/browse/{id}",
+		"/docs/*":           "https://docs.example.com/*",
+		"/docs/api/*":       "https://api.example.com/*",
+	}, http.NotFoundHandler())
+	tests := map[string]string{
+		"/gh/joncalhoun":          "https://github.com/joncalhoun",
+		"/gh/joncalhoun/urlshort": "https://github.com/joncalhoun/urlshort",
+		"/gh/gophercises":         "https://gophercises.com",
+		"/gh/a/b/c":               "https://github.com/search?q=a/b/c",
+		"/jira/PROJ-42":           "https://jira.example.com/browse/PROJ-42",
+		"/docs/guide/intro":       "https://docs.example.com/guide/intro",
+		"/docs/api/v1":            "https://api.example.com/v1",
+		"/docs":                   "https://docs.example.com/",
+		"/jira":                   "",
+		"/jira/":                  "",
+	}
+	for path, want := range tests {
+		if got := do(h, "GET", path, "").Header().Get("Location"); got != want {
+			t.Errorf("%s: expected a redirection to %q. Got %q", path, want, got)
+		}
+	}
+}
+
+func TestPassQuery(t *testing.T) {
+	h := RouteHandler(map[string]string{
+		"/go":       "https://golang.org",
+		"/search/*": "https://example.com/search?site=go#results",
+	}, http.NotFoundHandler(), RouteOptions{PassQuery: true})
+	tests := map[string]string{
+		"/go?tab=doc":      "https://golang.org?tab=doc",
+		"/go":              "https://golang.org",
+		"/search/x?q=maps": "https://example.com/search?site=go&q=maps#results",
+	}
+	for target, want := range tests {
+		if got := do(h, "GET", target, "").Header().Get("Location"); got != want {
+			t.Errorf("%s: expected a redirection to %q. Got %q", target, want, got)
+		}
+	}
+}
+
+func TestInvalidPatterns(t *testing.T) {
+	for _, yml := range []string{
+		"- path: /a/*/b\n  url: https://a.com\n",
+		"- path: /a/{x}/{x}\n  url: https://a.com/{x}\n",
+		"- path: /a/{x\n  url: https://a.com\n",
+		"- path: /a/{x}\n  url: https://a.com/{y}\n",
+		"- path: /a/{x}\n  url: https://a.com/*\n",
+	} {
+		if _, err := parseYAML([]byte(yml)); err == nil {
+			t.Errorf("Expected an error for %q", yml)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QROptions configure the QR codes of QRHandler
+type QROptions struct {
+	// BaseURL is the url of the shortener encoded before the path of the
+	// links, like https://sho.rt. The scheme and the host of the request
+	// are used when it is empty.
+	BaseURL string
+	// Size is the default width of the images in pixels, 256 if 0. The
+	// requests can ask for another one with the size parameter.
+	Size int
+	// Level is the default error correction level: L, M, Q or H, M if
+	// empty. The requests can ask for another one with the level
+	// parameter.
+	Level string
+}
+
+// maxQRSize is the largest image served
+const maxQRSize = 2048
+
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// QRHandler returns a middleware serving a QR code of the short url of
+// the links of next:
+//
+//	GET /{path}.png?size=512&level=H
+//	GET /{path}.svg
+//
+// A path is a link when next redirects it. The other requests are passed
+// to next. The links to an url rejected by the Blocklist enforced on the
+// request, if any, have no QR code.
+func QRHandler(next http.Handler, opts QROptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ext := path.Ext(r.URL.Path)
+		if (ext != ".png" && ext != ".svg") || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		link := strings.TrimSuffix(r.URL.Path, ext)
+		target, ok := isLink(next, r, link)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The redirect of the probe didn't go through the Blocklist
+		if check := blocked(r); check != nil {
+			if err := check(target); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+
+		size, level, err := qrParams(r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q, err := qrcode.New(shortURL(r, opts, link), level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var body []byte
+		if ext == ".png" {
+			w.Header().Set("Content-Type", "image/png")
+			if body, err = q.PNG(size); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			w.Header().Set("Content-Type", "image/svg+xml")
+			body = qrSVG(q.Bitmap(), size)
+		}
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Write(body)
+	})
+}
+
+func qrParams(r *http.Request, opts QROptions) (int, qrcode.RecoveryLevel, error) {
+	size := opts.Size
+	if s := r.URL.Query().Get("size"); s != "" {
+		var err error
+		if size, err = strconv.Atoi(s); err != nil || size <= 0 || size > maxQRSize {
+			return 0, 0, fmt.Errorf("size must be between 1 and %d", maxQRSize)
+		}
+	}
+	if size <= 0 {
+		size = 256
+	}
+	name := opts.Level
+	if l := r.URL.Query().Get("level"); l != "" {
+		name = l
+	}
+	if name == "" {
+		name = "M"
+	}
+	level, ok := qrLevels[strings.ToUpper(name)]
+	if !ok {
+		return 0, 0, fmt.Errorf("level must be L, M, Q or H")
+	}
+	return size, level, nil
+}
+
+// shortURL returns the full url of the link on path
+func shortURL(r *http.Request, opts QROptions, path string) string {
+	if opts.BaseURL != "" {
+		return strings.TrimSuffix(opts.BaseURL, "/") + path
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// qrSVG draws a QR code bitmap with one square per module
+func qrSVG(bitmap [][]bool, size int) []byte {
+	var b strings.Builder
+	n := len(bitmap)
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range bitmap {
+		for x, black := range row {
+			if black {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return []byte(b.String())
+}
+
+type probeKey struct{}
+
+// isLink asks next whether path is a link, without counting a redirect,
+// and returns the url it redirects to
+func isLink(next http.Handler, r *http.Request, path string) (string, bool) {
+	probe := r.Clone(context.WithValue(r.Context(), probeKey{}, true))
+	probe.Method = http.MethodHead
+	probe.URL.Path = path
+	probe.URL.RawPath = ""
+	probe.RequestURI = ""
+	w := &probeWriter{header: make(http.Header)}
+	next.ServeHTTP(w, probe)
+	return w.header.Get("Location"), isRedirect(w.status)
+}
+
+// probing tells whether a request only checks if a link exists, in which
+// case the redirect or the fallback must not be counted
+func probing(r *http.Request) bool {
+	return r.Context().Value(probeKey{}) != nil
+}
+
+// probeWriter keeps the status of a probe and drops the rest
+type probeWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *probeWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *probeWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *probeWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
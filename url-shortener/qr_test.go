@@ -0,0 +1,69 @@
+package urlshort
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestQRHandler(t *testing.T) {
+	s := testStore(t)
+	s.Create(Link{Path: "/once", URL: "https://a.com", Limits: Limits{MaxClicks: 1}})
+	files := MapHandler(map[string]string{"/go": "https://golang.org"}, StoreHandler(s, http.NotFoundHandler(), RouteOptions{}))
+	h := QRHandler(files, QROptions{Size: 100})
+
+	w := do(h, "GET", "/go.png?size=300&level=H", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Expected a PNG. Got status %d: %s", w.Code, w.Body)
+	}
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Dx(); size != 300 {
+		t.Errorf("Expected a 300 pixels image. Got %d", size)
+	}
+
+	w = do(h, "GET", "/once.svg", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "<svg") || !strings.Contains(w.Body.String(), `width="100"`) {
+		t.Errorf("Expected an SVG of 100 pixels. Got status %d: %s", w.Code, w.Body)
+	}
+	// Rendering the QR code isn't a click
+	if w := do(h, "GET", "/once", ""); w.Code != http.StatusFound {
+		t.Errorf("Expected the link to still redirect. Got status %d", w.Code)
+	}
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/missing.png", http.StatusNotFound},
+		{"/go.png?size=0", http.StatusBadRequest},
+		{"/go.png?level=X", http.StatusBadRequest},
+		{"/go.gif", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		if w := do(h, "GET", test.target, ""); w.Code != test.status {
+			t.Errorf("%s: expected status %d. Got %d", test.target, test.status, w.Code)
+		}
+	}
+}
+
+func TestQRProbe(t *testing.T) {
+	m := NewMetrics()
+	routes := MapHandler(map[string]string{"/go": "https://golang.org", "/bad": "https://evil.com"}, m.Fallback(http.NotFoundHandler()))
+	h := Blocklist{Domains: []string{"evil.com"}}.Handler(QRHandler(routes, QROptions{}))
+
+	if w := do(h, "GET", "/bad.png", ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected no QR code for a blocked link. Got %d", w.Code)
+	}
+	if w := do(h, "GET", "/go.png", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected a QR code. Got %d", w.Code)
+	}
+	do(h, "GET", "/missing.png", "")
+	if w := do(m, "GET", "/metrics", ""); !strings.Contains(w.Body.String(), "urlshort_fallbacks_total 1\n") {
+		t.Errorf("Expected the probe not to count as a fallback. Got:\n%s", w.Body)
+	}
+}
@@ -0,0 +1,91 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewRateLimiter(1, 2)
+	l.now = func() time.Time { return now }
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), http.MethodPost)
+
+	send := func(method, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/links", nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if w := send("POST", "10.0.0.1"); w.Code != want {
+			t.Errorf("Request %d: expected status %d. Got %d", i, want, w.Code)
+		}
+	}
+	if w := send("POST", "10.0.0.1"); w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected to retry after 1 second. Got %q", w.Header().Get("Retry-After"))
+	}
+	if w := send("POST", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("Expected another client to have its own bucket. Got status %d", w.Code)
+	}
+	if w := send("GET", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("Expected the other methods not to be limited. Got status %d", w.Code)
+	}
+
+	now = now.Add(time.Second)
+	if w := send("POST", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("Expected a token after a second. Got status %d", w.Code)
+	}
+	now = now.Add(time.Hour)
+	send("POST", "10.0.0.3")
+	if len(l.buckets) != 1 {
+		t.Errorf("Expected the full buckets to be swept. Got %d buckets", len(l.buckets))
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	s := testStore(t)
+	b := Blocklist{Domains: []string{"evil.com"}, SelfCheck: true, Hosts: []string{"sho.rt"}}
+	api := b.Handler(APIHandler(s, APIOptions{}))
+
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"https://golang.org", http.StatusCreated},
+		{"javascript:alert(1)", http.StatusBadRequest},
+		{"DATA:text/html,hi", http.StatusBadRequest},
+		{"https://www.evil.com/x", http.StatusBadRequest},
+		{"https://notevil.com", http.StatusCreated},
+		{"http://example.com/loop", http.StatusBadRequest},
+		{"https://sho.rt/abc", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if w := do(api, "POST", "/api/links", `{"url":"`+test.url+`"}`); w.Code != test.status {
+			t.Errorf("%s: expected status %d. Got %d", test.url, test.status, w.Code)
+		}
+	}
+
+	h := b.Handler(MapHandler(map[string]string{"/evil": "https://evil.com", "/go": "https://golang.org"}, http.NotFoundHandler()))
+	if w := do(h, "GET", "/evil", ""); w.Code != http.StatusForbidden || w.Header().Get("Location") != "" {
+		t.Errorf("Expected the redirect to a blocked domain to be forbidden. Got status %d", w.Code)
+	}
+	if w := do(h, "GET", "/go", ""); w.Code != http.StatusFound {
+		t.Errorf("Expected the other redirects to be served. Got status %d", w.Code)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := NewRateLimiter(0, 0)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("10.0.0.1"); !ok {
+			t.Fatalf("Expected a rate of 0 to allow every request. Request %d was limited", i)
+		}
+	}
+	if len(l.buckets) != 0 {
+		t.Errorf("Expected no bucket to be kept. Got %d", len(l.buckets))
+	}
+}
@@ -0,0 +1,105 @@
+package urlshort
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redirect configures the response of a link
+type Redirect struct {
+	// Status is the status code of the redirect: 301, 302, 307 or 308.
+	// 302 Found is used when it is 0.
+	Status int `json:"status,omitempty" yaml:"status,omitempty" toml:"status,omitempty"`
+	// CacheControl is the Cache-Control header of the redirect. When it
+	// is empty, the links with limits are sent with "no-store" so that
+	// browsers don't keep redirecting after they expire.
+	CacheControl string `json:"cache_control,omitempty" yaml:"cache_control,omitempty" toml:"cache_control,omitempty"`
+}
+
+func (rd Redirect) validate() error {
+	switch rd.Status {
+	case 0, http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	}
+	return fmt.Errorf("status %d must be 301, 302, 307 or 308", rd.Status)
+}
+
+// redirect answers a request with the redirect to address of a link
+func redirect(w http.ResponseWriter, r *http.Request, address string, rd Redirect, l Limits, opts RouteOptions) {
+	if opts.PassQuery && r.URL.RawQuery != "" {
+		address = withQuery(address, r.URL.RawQuery)
+	}
+	switch {
+	case rd.CacheControl != "":
+		w.Header().Set("Cache-Control", rd.CacheControl)
+	case l != (Limits{}):
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	status := rd.Status
+	if status == 0 {
+		status = http.StatusFound
+	}
+	http.Redirect(w, r, address, status)
+}
+
+// withQuery appends a query string to an url, keeping its fragment last
+func withQuery(address, query string) string {
+	u, err := url.Parse(address)
+	if err != nil {
+		return address
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&" + query
+	} else {
+		u.RawQuery = query
+	}
+	return u.String()
+}
+
+// previewPath returns the path of a link from the path of its preview
+// page, false if the request isn't for a preview
+func previewPath(r *http.Request, opts RouteOptions) (string, bool) {
+	if !opts.Preview || !strings.HasSuffix(r.URL.Path, "+") {
+		return "", false
+	}
+	return strings.TrimSuffix(r.URL.Path, "+"), true
+}
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+    <head>
+        <meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+        <meta name="robots" content="noindex">
+        <title>{{.Path}}</title>
+    </head>
+    <body>
+        <h1>{{.Path}}</h1>
+        <p>This link goes to:</p>
+        <p><a href="{{.URL}}" rel="noreferrer">{{.URL}}</a></p>
+        {{with .Status}}<p><strong>{{.}}</strong></p>{{end}}
+    </body>
+</html>
+`))
+
+// preview shows where a link goes instead of redirecting. The links which
+// aren't active yet are unknown and have no preview.
+func preview(w http.ResponseWriter, r *http.Request, path, address string, st status, opts RouteOptions) {
+	if opts.PassQuery && r.URL.RawQuery != "" {
+		address = withQuery(address, r.URL.RawQuery)
+	}
+	data := struct {
+		Path, URL, Status string
+	}{Path: path, URL: address}
+	if st == gone {
+		data.Status = "This link has expired."
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := previewTemplate.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
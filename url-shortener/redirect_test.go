@@ -0,0 +1,70 @@
+package urlshort
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedirectOptions(t *testing.T) {
+	yml := "- path: /moved\n  url: https://a.com\n  status: 301\n  cache_control: max-age=3600\n" +
+		"- path: /post\n  url: https://b.com\n  status: 307\n" +
+		"- path: /once\n  url: https://c.com\n  max_clicks: 1\n" +
+		"- path: /gh/{user}\n  url: https://github.com/{user}\n"
+	entries, err := parseYAML([]byte(yml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := routeHandler(buildMap(entries), http.NotFoundHandler(), RouteOptions{Preview: true}, newHits())
+
+	tests := []struct {
+		path, cache string
+		status      int
+	}{
+		{"/moved", "max-age=3600", http.StatusMovedPermanently},
+		{"/post", "", http.StatusTemporaryRedirect},
+		{"/once", "no-store", http.StatusFound},
+	}
+	for _, test := range tests {
+		w := do(h, "GET", test.path, "")
+		if w.Code != test.status || w.Header().Get("Cache-Control") != test.cache {
+			t.Errorf("%s: expected status %d and Cache-Control %q. Got %d and %q", test.path,
+				test.status, test.cache, w.Code, w.Header().Get("Cache-Control"))
+		}
+	}
+
+	w := do(h, "GET", "/gh/joncalhoun+", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `href="https://github.com/joncalhoun"`) {
+		t.Errorf("Expected a preview of the pattern. Got status %d: %s", w.Code, w.Body)
+	}
+	w = do(h, "GET", "/once+", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "expired") {
+		t.Errorf("Expected a preview of the expired link. Got status %d: %s", w.Code, w.Body)
+	}
+	if w := do(h, "GET", "/missing+", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected no preview of an unknown link. Got status %d", w.Code)
+	}
+
+	if _, err := parseYAML([]byte("- path: /a\n  url: https://a.com\n  status: 200\n")); err == nil {
+		t.Error("Expected an error for a status which isn't a redirect")
+	}
+}
+
+func TestStorePreview(t *testing.T) {
+	s := testStore(t)
+	later := time.Now().Add(time.Hour)
+	s.Create(Link{Path: "/go", URL: "https://golang.org", Redirect: Redirect{Status: http.StatusPermanentRedirect}})
+	s.Create(Link{Path: "/soon", URL: "https://soon.com", Limits: Limits{NotBefore: &later}})
+	h := StoreHandler(s, http.NotFoundHandler(), RouteOptions{Preview: true})
+
+	if w := do(h, "GET", "/go", ""); w.Code != http.StatusPermanentRedirect {
+		t.Errorf("Expected a 308 redirect. Got status %d", w.Code)
+	}
+	if w := do(h, "GET", "/go+", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "https://golang.org") {
+		t.Errorf("Expected a preview of /go. Got status %d: %s", w.Code, w.Body)
+	}
+	if w := do(h, "GET", "/soon+", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected no preview of a link not active yet. Got status %d", w.Code)
+	}
+}
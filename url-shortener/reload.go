@@ -0,0 +1,167 @@
+package urlshort
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ReloadHandler serves the redirects of several sources and
+// reloads them when they change. A source which becomes invalid
+// keeps being served from its last good version. When several
+// sources define the same path, the first one wins.
+type ReloadHandler struct {
+	sources  []Source
+	fallback http.Handler
+	opts     RouteOptions
+	// hits are kept across the reloads
+	hits *hits
+
+	// handler is the http.Handler built from the last reload. It is
+	// replaced as a whole so that requests never see a partial mapping.
+	handler atomic.Value
+
+	mu sync.Mutex
+	// entries are the last good entries of each source
+	entries [][]entry
+	// modTimes are the modification times of the last version seen of
+	// the files
+	modTimes []time.Time
+}
+
+// NewReloadHandler loads the sources, which must all be valid,
+// and returns a handler redirecting their paths. If the path is
+// not in any source, then the fallback http.Handler will be
+// called instead. The sources made with FileSource are reloaded
+// by Watch when their file changes.
+func NewReloadHandler(fallback http.Handler, sources ...Source) (*ReloadHandler, error) {
+	h := &ReloadHandler{
+		sources:  sources,
+		fallback: fallback,
+		hits:     newHits(),
+		entries:  make([][]entry, len(sources)),
+		modTimes: make([]time.Time, len(sources)),
+	}
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *ReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// Reload loads every source again and swaps in the new mapping.
+// The sources that fail to load keep their previous entries, and
+// the first error is returned.
+func (h *ReloadHandler) Reload() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var firstErr error
+	for i := range h.sources {
+		if err := h.load(i); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	h.swap()
+	return firstErr
+}
+
+// load reads a source and keeps its entries if it is valid
+func (h *ReloadHandler) load(i int) error {
+	var modTime time.Time
+	if ws, ok := h.sources[i].(watchedSource); ok {
+		var err error
+		if modTime, err = ws.modTime(); err != nil {
+			return err
+		}
+	}
+	entries, err := h.sources[i].Entries()
+	if err != nil {
+		return err
+	}
+	h.entries[i] = entries
+	h.modTimes[i] = modTime
+	return nil
+}
+
+// SetOptions changes how the redirects are built
+func (h *ReloadHandler) SetOptions(opts RouteOptions) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.opts = opts
+	h.swap()
+}
+
+func (h *ReloadHandler) swap() {
+	h.handler.Store(http.Handler(routeHandler(mergeEntries(h.entries), h.fallback, h.opts, h.hits)))
+}
+
+// changed reloads the files modified since their last load
+func (h *ReloadHandler) changed() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	reloaded := false
+	for i, src := range h.sources {
+		ws, ok := src.(watchedSource)
+		if !ok {
+			continue
+		}
+		modTime, err := ws.modTime()
+		if err != nil || modTime.Equal(h.modTimes[i]) {
+			continue
+		}
+		if err := h.load(i); err != nil {
+			log.Printf("keeping the last good version of %s: %v", src, err)
+			// Don't try again until the file changes
+			h.modTimes[i] = modTime
+			continue
+		}
+		log.Printf("reloaded %s", src)
+		reloaded = true
+	}
+	if reloaded {
+		h.swap()
+	}
+}
+
+// Watch checks the files for changes every interval, and reloads
+// them all on SIGHUP. It returns a function stopping the watch.
+func (h *ReloadHandler) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ticker.C:
+				h.changed()
+			case <-hup:
+				if err := h.Reload(); err != nil {
+					log.Printf("reload: %v", err)
+				} else {
+					log.Printf("reloaded %d sources", len(h.sources))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
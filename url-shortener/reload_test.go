@@ -0,0 +1,85 @@
+package urlshort
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "urlshort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jsonFile := filepath.Join(dir, "links.json")
+	yamlFile := filepath.Join(dir, "links.yml")
+	write := func(name, content string, modTime time.Time) {
+		if err := ioutil.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(name, modTime, modTime)
+	}
+	location := func(h http.Handler, path string) string {
+		return do(h, "GET", path, "").Header().Get("Location")
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write(jsonFile, `[{"path":"/go","url":"https://golang.org"}]`, start)
+	write(yamlFile, "- path: /go\n  url: https://go.dev\n- path: /yaml\n  url: https://yaml.org\n", start)
+	h, err := NewReloadHandler(http.NotFoundHandler(), FileSource(jsonFile), FileSource(yamlFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := location(h, "/go"); got != "https://golang.org" {
+		t.Errorf("Expected the first file to win. Got %q", got)
+	}
+
+	write(jsonFile, `[{"path":"/go","url":"https://go.dev/doc"}]`, start.Add(time.Minute))
+	h.changed()
+	if got := location(h, "/go"); got != "https://go.dev/doc" {
+		t.Errorf("Expected the modified file to be reloaded. Got %q", got)
+	}
+
+	write(yamlFile, "- path: [/yaml\n", start.Add(2*time.Minute))
+	h.changed()
+	if got := location(h, "/yaml"); got != "https://yaml.org" {
+		t.Errorf("Expected the last good version of an invalid file to be kept. Got %q", got)
+	}
+	if err := h.Reload(); err == nil {
+		t.Error("Expected Reload to report the invalid file")
+	}
+}
+
+func TestReloadMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "urlshort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "links.yml")
+	h, err := NewReloadHandler(http.NotFoundHandler(), FileSource(name))
+	if err != nil {
+		t.Fatalf("Expected a missing file to have no redirects. Got %v", err)
+	}
+	if w := do(h, "GET", "/go", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the fallback. Got %d", w.Code)
+	}
+
+	if err := ioutil.WriteFile(name, []byte("- path: /go\n  url: https://go.dev\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	h.changed()
+	if got := do(h, "GET", "/go", "").Header().Get("Location"); got != "https://go.dev" {
+		t.Errorf("Expected the file to be loaded once created. Got %q", got)
+	}
+
+	os.Remove(name)
+	h.changed()
+	if w := do(h, "GET", "/go", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the redirects of a removed file to be dropped. Got %d", w.Code)
+	}
+}
@@ -0,0 +1,270 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// A Source provides redirect entries. Sources are composed with
+// SourcesHandler or NewReloadHandler, the first source defining
+// a path taking precedence over the next ones.
+type Source interface {
+	Entries() ([]entry, error)
+}
+
+// watchedSource is implemented by the sources able to tell when
+// they last changed
+type watchedSource interface {
+	Source
+	modTime() (time.Time, error)
+}
+
+// SourcesHandler will load every source and return an
+// http.HandlerFunc redirecting the paths they define. When
+// several sources define the same path, the first one wins. If
+// the path is not in any source, then the fallback http.Handler
+// will be called instead.
+func SourcesHandler(fallback http.Handler, sources ...Source) (http.HandlerFunc, error) {
+	all := make([][]entry, len(sources))
+	for i, src := range sources {
+		entries, err := src.Entries()
+		if err != nil {
+			return nil, err
+		}
+		all[i] = entries
+	}
+	return routeHandler(mergeEntries(all), fallback, RouteOptions{}, newHits()), nil
+}
+
+// mergeEntries builds the mapping of several lists of entries, the first
+// list defining a path winning
+func mergeEntries(all [][]entry) map[string]route {
+	pathMap := make(map[string]route)
+	for i := len(all) - 1; i >= 0; i-- {
+		for path, url := range buildMap(all[i]) {
+			pathMap[path] = url
+		}
+	}
+	return pathMap
+}
+
+type sourceFunc func() ([]entry, error)
+
+func (f sourceFunc) Entries() ([]entry, error) {
+	return f()
+}
+
+// MapSource returns a Source of the given paths to urls
+func MapSource(pathsToUrls map[string]string) Source {
+	return sourceFunc(func() ([]entry, error) {
+		paths := make([]string, 0, len(pathsToUrls))
+		for path := range pathsToUrls {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		entries := make([]entry, len(paths))
+		for i, path := range paths {
+			entries[i] = entry{Key: path, Value: pathsToUrls[path]}
+		}
+		return entries, validate(entries, nil)
+	})
+}
+
+// YAMLSource returns a Source of YAML data in the format accepted
+// by YAMLHandler
+func YAMLSource(yml []byte) Source {
+	return sourceFunc(func() ([]entry, error) {
+		return parseYAML(yml)
+	})
+}
+
+// JSONSource returns a Source of JSON data in the format accepted
+// by JSONHandler
+func JSONSource(jsn []byte) Source {
+	return sourceFunc(func() ([]entry, error) {
+		return parseJSON(jsn)
+	})
+}
+
+// TOMLSource returns a Source of TOML data in the format:
+//
+//	[[links]]
+//	path = "/some-path"
+//	url = "https://www.some-url.com/demo"
+func TOMLSource(tml []byte) Source {
+	return sourceFunc(func() ([]entry, error) {
+		return parseTOML(tml)
+	})
+}
+
+// CSVSource returns a Source of CSV data with a path and an url
+// on each line. The first line is skipped if it is the header
+// "path,url". The links of a CSV source have no limits.
+func CSVSource(data []byte) Source {
+	return sourceFunc(func() ([]entry, error) {
+		return parseCSV(data)
+	})
+}
+
+// EnvSource returns a Source of the environment variables
+// starting with prefix. The rest of the name of the variable
+// gives the path, in lower case with dashes instead of
+// underscores, and the value is the url:
+//
+//	URLSHORT_GO_DOC=https://golang.org/doc
+//
+// redirects /go-doc with the prefix "URLSHORT_".
+func EnvSource(prefix string) Source {
+	return sourceFunc(func() ([]entry, error) {
+		env := os.Environ()
+		sort.Strings(env)
+		var entries []entry
+		var names []string
+		for _, kv := range env {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 || !strings.HasPrefix(parts[0], prefix) || parts[0] == prefix {
+				continue
+			}
+			path := strings.TrimPrefix(parts[0], prefix)
+			path = "/" + strings.Replace(strings.ToLower(path), "_", "-", -1)
+			entries = append(entries, entry{Key: path, Value: parts[1]})
+			names = append(names, "$"+parts[0])
+		}
+		err := validate(entries, nil)
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			// Name the variable rather than its index
+			perr.File, perr.Entry = names[perr.Entry], -1
+		}
+		return entries, err
+	})
+}
+
+type fileSource struct {
+	name string
+}
+
+// FileSource returns a Source reading a file in one of the
+// formats above, depending on its extension: .yml or .yaml,
+// .json, .toml or .csv. The file is read again every time the
+// entries are loaded. A missing file has no entries, until it is
+// created.
+func FileSource(name string) Source {
+	return fileSource{name: name}
+}
+
+func (f fileSource) Entries() ([]entry, error) {
+	data, err := ioutil.ReadFile(f.name)
+	if os.IsNotExist(err) {
+		log.Printf("%s doesn't exist, it has no redirects", f.name)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var src Source
+	switch filepath.Ext(f.name) {
+	case ".yml", ".yaml":
+		src = YAMLSource(data)
+	case ".json":
+		src = JSONSource(data)
+	case ".toml":
+		src = TOMLSource(data)
+	case ".csv":
+		src = CSVSource(data)
+	default:
+		return nil, fmt.Errorf("%s: unknown file format", f.name)
+	}
+	entries, err := src.Entries()
+	return entries, inFile(err, f.name)
+}
+
+func (f fileSource) modTime() (time.Time, error) {
+	info, err := os.Stat(f.name)
+	if os.IsNotExist(err) {
+		// Reloaded when it is created
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (f fileSource) String() string {
+	return f.name
+}
+
+func parseTOML(tml []byte) ([]entry, error) {
+	var doc struct {
+		Links []entry `toml:"links"`
+	}
+	md, err := toml.Decode(string(tml), &doc)
+	if err != nil {
+		perr := &ParseError{Entry: -1, Err: err}
+		var terr toml.ParseError
+		if errors.As(err, &terr) {
+			perr.Line = terr.Position.Line
+		}
+		return nil, perr
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, &ParseError{Entry: -1, Err: fmt.Errorf("unknown key %q", undecoded[0].String())}
+	}
+
+	var lines []int
+	for i, line := range bytes.Split(tml, []byte("\n")) {
+		if string(bytes.TrimSpace(line)) == "[[links]]" {
+			lines = append(lines, i+1)
+		}
+	}
+	if err := validate(doc.Links, lines); err != nil {
+		return nil, err
+	}
+	return doc.Links, nil
+}
+
+func parseCSV(data []byte) ([]entry, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	var entries []entry
+	var lines []int
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			perr := &ParseError{Entry: -1, Err: err}
+			var cerr *csv.ParseError
+			if errors.As(err, &cerr) {
+				perr.Line = cerr.Line
+			}
+			return nil, perr
+		}
+		line, _ := r.FieldPos(0)
+		if line == 1 && record[0] == "path" && record[1] == "url" {
+			continue
+		}
+		entries = append(entries, entry{Key: record[0], Value: record[1]})
+		lines = append(lines, line)
+	}
+	if err := validate(entries, lines); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
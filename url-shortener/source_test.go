@@ -0,0 +1,59 @@
+package urlshort
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestSourcesHandler(t *testing.T) {
+	os.Setenv("URLSHORT_TEST_GO_DOC", "https://golang.org/doc")
+	defer os.Unsetenv("URLSHORT_TEST_GO_DOC")
+
+	h, err := SourcesHandler(http.NotFoundHandler(),
+		TOMLSource([]byte("[[links]]\npath = \"/toml\"\nurl = \"https://toml.io\"\n")),
+		CSVSource([]byte("path,url\n/csv,https://csv.org\n/toml,https://example.com\n")),
+		EnvSource("URLSHORT_TEST_"),
+		MapSource(map[string]string{"/map": "https://golang.org", "/csv": "https://example.com"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/toml":   "https://toml.io",
+		"/csv":    "https://csv.org",
+		"/go-doc": "https://golang.org/doc",
+		"/map":    "https://golang.org",
+	} {
+		if got := do(h, "GET", path, "").Header().Get("Location"); got != want {
+			t.Errorf("Expected %s to redirect to %s. Got %q", path, want, got)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  Source
+		line int
+	}{
+		{"toml syntax", TOMLSource([]byte("[[links]]\npath = \"/a\nurl = \"https://a.com\"\n")), 2},
+		{"toml unknown key", TOMLSource([]byte("[[links]]\npath = \"/a\"\nlink = \"https://a.com\"\n")), 0},
+		{"toml invalid url", TOMLSource([]byte("[[links]]\npath = \"/a\"\nurl = \"https://a.com\"\n\n[[links]]\npath = \"/b\"\nurl = \"ftp://b.com\"\n")), 5},
+		{"yaml document marker", YAMLSource([]byte("---\n- path: /a\n  url: https://a.com\n- path: /b\n  url: ftp://b.com\n")), 4},
+		{"csv fields", CSVSource([]byte("/a,https://a.com\n/b\n")), 2},
+		{"csv duplicate", CSVSource([]byte("path,url\n/a,https://a.com\n/a,https://b.com\n")), 3},
+	}
+	for _, test := range tests {
+		_, err := test.src.Entries()
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: expected a *ParseError. Got %v", test.name, err)
+			continue
+		}
+		if perr.Line != test.line {
+			t.Errorf("%s: expected an error on line %d. Got %q", test.name, test.line, perr)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package urlshort
+
+import (
+	"encoding/csv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatsPrefix is the path under which StatsHandler serves the analytics
+const StatsPrefix = "/api/stats"
+
+// LinkClicks is the number of clicks of a link
+type LinkClicks struct {
+	Path   string `json:"path"`
+	Clicks int    `json:"clicks"`
+}
+
+// Bucket is the number of clicks during an hour or a day
+type Bucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int       `json:"clicks"`
+}
+
+// LinkStats are the clicks of a link grouped by hour or day
+type LinkStats struct {
+	Path    string   `json:"path"`
+	Total   int      `json:"total"`
+	Bucket  string   `json:"bucket"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// StatsHandler returns an http.Handler serving the clicks recorded
+// in the store:
+//
+//	GET /api/stats         the number of clicks of every link
+//	GET /api/stats/{path}  the clicks of a link grouped by time
+//
+// The clicks of a link are grouped by day unless the bucket
+// parameter is "hour", and can be limited to a recent period
+// with the since parameter (a duration like 24h). Both endpoints
+// answer in CSV instead of JSON with format=csv.
+//
+// When opts.AdminToken is set, the requests need the bearer token of a
+// User, who only sees the clicks of the links it can read, like with
+// APIHandler.
+func StatsHandler(s *Store, opts APIOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := authenticate(s, opts, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, StatsPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		csv := r.URL.Query().Get("format") == "csv"
+		path := strings.TrimPrefix(r.URL.Path, StatsPrefix)
+		if path == "" || path == "/" {
+			totals, err := clickTotals(s, user)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			if csv {
+				writeTotalsCSV(w, totals)
+				return
+			}
+			writeJSON(w, http.StatusOK, totals)
+			return
+		}
+
+		if !user.canRead(path) {
+			writeError(w, ErrForbidden)
+			return
+		}
+		stats, err := linkStats(s, path, r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+			return
+		}
+		if csv {
+			writeBucketsCSV(w, stats)
+			return
+		}
+		writeJSON(w, http.StatusOK, stats)
+	})
+}
+
+// clickTotals returns the number of clicks of the links user can read
+func clickTotals(s *Store, user *User) ([]LinkClicks, error) {
+	counts, err := s.ClickCounts()
+	if err != nil {
+		return nil, err
+	}
+	totals := make([]LinkClicks, 0, len(counts))
+	for path, n := range counts {
+		if !user.canRead(path) {
+			continue
+		}
+		totals = append(totals, LinkClicks{Path: path, Clicks: n})
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Path < totals[j].Path
+	})
+	return totals, nil
+}
+
+func linkStats(s *Store, path string, r *http.Request) (LinkStats, error) {
+	stats := LinkStats{Path: path, Bucket: "day", Buckets: []Bucket{}}
+	truncate := func(t time.Time) time.Time {
+		y, m, d := t.UTC().Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	if r.URL.Query().Get("bucket") == "hour" {
+		stats.Bucket = "hour"
+		truncate = func(t time.Time) time.Time {
+			return t.UTC().Truncate(time.Hour)
+		}
+	}
+	var since time.Time
+	if d := r.URL.Query().Get("since"); d != "" {
+		period, err := time.ParseDuration(d)
+		if err != nil {
+			return stats, err
+		}
+		since = time.Now().Add(-period)
+	}
+
+	clicks, err := s.Clicks(path, since)
+	if err != nil {
+		return stats, err
+	}
+	for _, c := range clicks {
+		start := truncate(c.Time)
+		if n := len(stats.Buckets); n > 0 && stats.Buckets[n-1].Start.Equal(start) {
+			stats.Buckets[n-1].Clicks++
+		} else {
+			stats.Buckets = append(stats.Buckets, Bucket{Start: start, Clicks: 1})
+		}
+		stats.Total++
+	}
+	return stats, nil
+}
+
+func writeTotalsCSV(w http.ResponseWriter, totals []LinkClicks) {
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"path", "clicks"})
+	for _, t := range totals {
+		cw.Write([]string{t.Path, strconv.Itoa(t.Clicks)})
+	}
+	cw.Flush()
+}
+
+func writeBucketsCSV(w http.ResponseWriter, stats LinkStats) {
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"path", "start", "clicks"})
+	for _, b := range stats.Buckets {
+		cw.Write([]string{stats.Path, b.Start.Format(time.RFC3339), strconv.Itoa(b.Clicks)})
+	}
+	cw.Flush()
+}
@@ -0,0 +1,110 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClickAnalytics(t *testing.T) {
+	s := testStore(t)
+	if err := s.Create(Link{Path: "/go", URL: "https://golang.org"}); err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder(s, 10, "salt")
+	h := rec.Handler(StoreHandler(s, http.NotFoundHandler(), RouteOptions{}))
+	for i := 0; i < 3; i++ {
+		do(h, "GET", "/go", "")
+	}
+	do(h, "GET", "/missing", "")
+	rec.Close()
+
+	stats := StatsHandler(s, APIOptions{})
+	var totals []LinkClicks
+	json.NewDecoder(do(stats, "GET", "/api/stats", "").Body).Decode(&totals)
+	if len(totals) != 1 || totals[0] != (LinkClicks{Path: "/go", Clicks: 3}) {
+		t.Errorf("Expected 3 clicks on /go only. Got %+v", totals)
+	}
+
+	var link LinkStats
+	json.NewDecoder(do(stats, "GET", "/api/stats/go?bucket=hour", "").Body).Decode(&link)
+	if link.Total != 3 || len(link.Buckets) != 1 || link.Buckets[0].Clicks != 3 {
+		t.Errorf("Expected 3 clicks in a single hour. Got %+v", link)
+	}
+
+	csv := do(stats, "GET", "/api/stats/go?format=csv", "").Body.String()
+	if lines := strings.Split(strings.TrimSpace(csv), "\n"); len(lines) != 2 || !strings.HasSuffix(lines[1], ",3") {
+		t.Errorf("Expected a CSV header and a daily bucket of 3 clicks. Got:\n%s", csv)
+	}
+
+	clicks, _ := s.Clicks("/go", link.Buckets[0].Start)
+	if len(clicks) != 3 || clicks[0].IPHash == "" || strings.Contains(clicks[0].IPHash, "192.0.2.1") {
+		t.Errorf("Expected the clicks to keep a hash of the IP. Got %+v", clicks)
+	}
+}
+
+func TestClicksOfLinksOnly(t *testing.T) {
+	s := testStore(t)
+	if err := s.Create(Link{Path: "/go", URL: "https://golang.org"}); err != nil {
+		t.Fatal(err)
+	}
+	seeOther := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/admin/", http.StatusSeeOther)
+	})
+	routes := MapHandler(map[string]string{"/gh/{user}": "https://github.com/{user}"}, seeOther)
+	rec := NewRecorder(s, 10, "salt")
+	h := rec.Handler(StoreHandler(s, routes, RouteOptions{}))
+	do(h, "GET", "/go", "")
+	do(h, "GET", "/gh/gopher", "")
+	do(h, "POST", "/admin/links", "")
+	rec.Close()
+
+	counts, err := s.ClickCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 1 || counts["/go"] != 1 {
+		t.Errorf("Expected a single click on /go. Got %v", counts)
+	}
+}
+
+func TestStatsNamespaces(t *testing.T) {
+	s := testStore(t)
+	now := time.Now()
+	if err := s.saveClicks([]Click{{Path: "/team-a/wiki", Time: now}, {Path: "/team-b/docs", Time: now}}); err != nil {
+		t.Fatal(err)
+	}
+	bob, err := s.CreateToken(User{Name: "bob", Namespaces: []string{"team-b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := StatsHandler(s, APIOptions{AdminToken: "root"})
+	get := func(target, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		stats.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := get("/api/stats", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a token to be required. Got %d", w.Code)
+	}
+	var totals []LinkClicks
+	json.NewDecoder(get("/api/stats", bob).Body).Decode(&totals)
+	if len(totals) != 1 || totals[0].Path != "/team-b/docs" {
+		t.Errorf("Expected bob to only see the clicks of team-b. Got %+v", totals)
+	}
+	if w := get("/api/stats/team-a/wiki", bob); w.Code != http.StatusForbidden {
+		t.Errorf("Expected bob not to see the clicks of team-a. Got %d", w.Code)
+	}
+	json.NewDecoder(get("/api/stats", "root").Body).Decode(&totals)
+	if len(totals) != 2 {
+		t.Errorf("Expected an admin to see every link. Got %+v", totals)
+	}
+}
@@ -0,0 +1,213 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var linksBucket = []byte("links")
+
+var (
+	// ErrNotFound is returned when a link doesn't exist in the store
+	ErrNotFound = errors.New("link not found")
+	// ErrExists is returned when creating a link on a path already in use
+	ErrExists = errors.New("link already exists")
+)
+
+// Link is a short link kept in a Store
+type Link struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+	Limits
+	Redirect
+	// Served is the number of redirects served, only counted for the
+	// links with a MaxClicks
+	Served int `json:"served,omitempty"`
+	// Owner is the name of the user who created the link
+	Owner string `json:"owner,omitempty"`
+	// Generated is true when the path was generated by the store
+	Generated bool `json:"generated,omitempty"`
+	// Health is the result of the last checks of the url, nil until it
+	// is checked
+	Health  *Health   `json:"health,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// validate checks the url and the options of a link
+func (l Link) validate() error {
+	if l.URL == "" {
+		return errors.New("url is required")
+	}
+	if err := validateURL(l.URL); err != nil {
+		return err
+	}
+	if err := l.Limits.validate(); err != nil {
+		return err
+	}
+	return l.Redirect.validate()
+}
+
+// Store keeps the links in a BoltDB database so that they can be changed
+// while the server runs
+type Store struct {
+	db *bolt.DB
+}
+
+// OpenStore opens (or creates) the database at dbpath
+func OpenStore(dbpath string) (*Store, error) {
+	db, err := bolt.Open(dbpath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(linksBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get returns the link of the given path
+func (s *Store) Get(path string) (Link, error) {
+	var l Link
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		l, err = getLink(tx.Bucket(linksBucket), path)
+		return err
+	})
+	return l, err
+}
+
+// List returns all the links, sorted by path
+func (s *Store) List() ([]Link, error) {
+	var ret []Link
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(linksBucket).ForEach(func(k, v []byte) error {
+			var l Link
+			if err := json.Unmarshal(v, &l); err != nil {
+				return err
+			}
+			ret = append(ret, l)
+			return nil
+		})
+	})
+	return ret, err
+}
+
+// Create adds a new link. It fails with ErrExists if the path is taken.
+func (s *Store) Create(l Link) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return createLink(tx.Bucket(linksBucket), &l)
+	})
+}
+
+// Update replaces the target and the options of an existing link
+func (s *Store) Update(l Link) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(linksBucket)
+		old, err := getLink(b, l.Path)
+		if err != nil {
+			return err
+		}
+		l.Created = old.Created
+		l.Generated = old.Generated
+		l.Served = old.Served
+		l.Owner = old.Owner
+		l.Health = nil
+		if l.URL == old.URL {
+			l.Health = old.Health
+		}
+		l.Updated = time.Now()
+		return putLink(b, l)
+	})
+}
+
+// Delete removes the link of the given path and its clicks
+func (s *Store) Delete(path string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteLink(tx, path)
+	})
+}
+
+func deleteLink(tx *bolt.Tx, path string) error {
+	b := tx.Bucket(linksBucket)
+	if b.Get([]byte(path)) == nil {
+		return ErrNotFound
+	}
+	if clicksOf(tx, path) != nil {
+		if err := tx.Bucket(clicksBucket).DeleteBucket([]byte(path)); err != nil {
+			return err
+		}
+	}
+	return b.Delete([]byte(path))
+}
+
+func createLink(b *bolt.Bucket, l *Link) error {
+	if b.Get([]byte(l.Path)) != nil {
+		return ErrExists
+	}
+	l.Created = time.Now()
+	l.Updated = l.Created
+	return putLink(b, *l)
+}
+
+func getLink(b *bolt.Bucket, path string) (Link, error) {
+	var l Link
+	v := b.Get([]byte(path))
+	if v == nil {
+		return l, ErrNotFound
+	}
+	return l, json.Unmarshal(v, &l)
+}
+
+func putLink(b *bolt.Bucket, l Link) error {
+	v, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(l.Path), v)
+}
+
+// StoreHandler will return an http.HandlerFunc that redirects
+// the paths of the links kept in the store. The store is read
+// on every request, so links can be added or removed while the
+// server runs. If the path is not in the store, then the
+// fallback http.Handler will be called instead. The links which
+// expired are handled as described by RouteOptions.
+func StoreHandler(s *Store, fallback http.Handler, opts RouteOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if path, ok := previewPath(r, opts); ok {
+			if l, err := s.Get(path); err == nil {
+				if st := l.status(time.Now(), l.Served); st != pending {
+					preview(w, r, path, l.URL, st, opts)
+					return
+				}
+			}
+		}
+		l, st, err := s.hit(r.URL.Path, time.Now(), !probing(r))
+		switch {
+		case err == nil && st != active:
+			inactive(w, r, st, fallback, opts)
+		case err == nil:
+			resolveLink(r, l.Path)
+			redirect(w, r, l.URL, l.Redirect, l.Limits, opts)
+		case err == ErrNotFound:
+			fallback.ServeHTTP(w, r)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}